@@ -0,0 +1,197 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"minimum/bytecode"
+	"minimum/inter"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BUNDLE START
+
+// A bundled executable is an interpreter binary followed by the gob encoded
+// bundle, its length as 8 little endian bytes and bundleMagic. The
+// interpreter looks for that trailer in its own file before anything else.
+
+var bundleMagic = []byte("MINBNDL1")
+
+type bundle struct {
+	Main     string            // path of the main script, as given to -bundle
+	Template string            // text of the MinT file given with -template
+	Files    map[string][]byte // compiled scripts by the path !source or import uses
+	Path     string            // MINIMUM_PATH when bundling, where imports found the modules
+}
+
+// read_bundle returns the bundle appended to the executable at path, reading
+// only its end.
+func read_bundle(path string) (bundle, bool) {
+	b := bundle{}
+	f, err := os.Open(path)
+	if err != nil {
+		return b, false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return b, false
+	}
+	trailer := make([]byte, 8+len(bundleMagic))
+	if info.Size() < int64(len(trailer)) {
+		return b, false
+	}
+	if _, err := f.ReadAt(trailer, info.Size()-int64(len(trailer))); err != nil || !bytes.Equal(trailer[8:], bundleMagic) {
+		return b, false
+	}
+	length := binary.LittleEndian.Uint64(trailer)
+	if length > uint64(info.Size())-uint64(len(trailer)) {
+		return b, false
+	}
+	data := make([]byte, length)
+	if _, err := f.ReadAt(data, info.Size()-int64(len(trailer))-int64(length)); err != nil {
+		return b, false
+	}
+	if gob.NewDecoder(bytes.NewReader(data)).Decode(&b) != nil {
+		return b, false
+	}
+	return b, true
+}
+
+// interpreter_size returns the length of the interpreter in an executable,
+// leaving out the bundle already appended to it.
+func interpreter_size(exe []byte) int {
+	trailer := 8 + len(bundleMagic)
+	if len(exe) < trailer || !bytes.Equal(exe[len(exe)-len(bundleMagic):], bundleMagic) {
+		return len(exe)
+	}
+	length := binary.LittleEndian.Uint64(exe[len(exe)-trailer:])
+	if length > uint64(len(exe)-trailer) {
+		return len(exe)
+	}
+	return len(exe) - trailer - int(length)
+}
+
+// run_bundle runs the main script of a bundle with its templates and sources.
+func run_bundle(b bundle) {
+	if b.Template != "" {
+		bytecode.LoadMinT(b.Template)
+	}
+	inter.Bundled = b.Files
+	if b.Path != "" {
+		os.Setenv("MINIMUM_PATH", b.Path+string(os.PathListSeparator)+os.Getenv("MINIMUM_PATH"))
+	}
+	unit, err := bytecode.Decode(b.Files[b.Main])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	run_unit(unit, b.Main)
+}
+
+// bundle_file writes an executable running fname: the interpreter given with
+// -base (this one by default) followed by the compiled script, every file it
+// loads with `!source` of a string constant or imports and the MinT templates.
+func bundle_file(fname string) error {
+	b := bundle{Main: fname, Files: make(map[string][]byte), Path: os.Getenv("MINIMUM_PATH")}
+	if uses_template {
+		text, err := os.ReadFile(flag_value("-template"))
+		if err != nil {
+			return err
+		}
+		b.Template = string(text)
+	}
+	pending := []string{fname}
+	for len(pending) > 0 {
+		path := pending[0]
+		pending = pending[1:]
+		if _, ok := b.Files[path]; ok {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("bundling %s: %v", path, err)
+		}
+		unit, err := compile_unit(data, path)
+		if err != nil {
+			return err
+		}
+		b.Files[path] = unit.Encode()
+		pending = append(pending, sourced(unit, path)...)
+	}
+	base := flag_value("-base")
+	if base == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		base = exe
+	}
+	data, err := os.ReadFile(base)
+	if err != nil {
+		return err
+	}
+	out := flag_value("-o")
+	if out == "" {
+		out = strings.TrimSuffix(filepath.Base(fname), filepath.Ext(fname))
+	}
+	exe, err := append_bundle(data, b)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(out, exe, 0755)
+}
+
+// append_bundle returns the interpreter in exe followed by b and the trailer,
+// replacing the bundle exe already has.
+func append_bundle(exe []byte, b bundle) ([]byte, error) {
+	size := interpreter_size(exe)
+	payload := bytes.Buffer{}
+	if err := gob.NewEncoder(&payload).Encode(b); err != nil {
+		return nil, err
+	}
+	exe = append(exe[:size:size], payload.Bytes()...)
+	exe = binary.LittleEndian.AppendUint64(exe, uint64(payload.Len()))
+	return append(exe, bundleMagic...), nil
+}
+
+// sourced lists the paths the unit, compiled from fname, loads with `!source`
+// of a string constant and the modules it imports, under the path the import
+// finds them at. Paths computed while running cannot be known and are read
+// from the disk.
+func sourced(unit bytecode.Unit, fname string) []string {
+	paths := []string{}
+	for _, acts := range unit.Code {
+		constants := make(map[string]string)
+		for _, act := range acts {
+			switch {
+			case act.Type == "source" && len(act.Variables) == 1:
+				if path, ok := constants[act.First()]; ok {
+					paths = append(paths, path)
+				}
+			case act.Type == "<import>":
+				for _, path := range inter.ModulePaths(fname, constants[act.First()]) {
+					if _, err := os.Stat(path); err == nil {
+						paths = append(paths, path)
+						break
+					}
+				}
+			case act.Type == "const":
+				if s, ok := act.Value.(string); ok {
+					constants[act.Target] = s
+					continue
+				}
+			}
+			delete(constants, act.Target)
+		}
+	}
+	return paths
+}
+
+// BUNDLE END
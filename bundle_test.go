@@ -0,0 +1,62 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"minimum/bytecode"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBundleTrailer(t *testing.T) {
+	interpreter := []byte("\x7fELF not really an interpreter")
+	b := bundle{
+		Main:     "main.min",
+		Template: "<p>{x}</p>",
+		Files: map[string][]byte{
+			"main.min": bytecode.GetCode("!source \"lib.min\"\n!print x").Encode(),
+			"lib.min":  bytecode.GetCode("x = 1").Encode(),
+		},
+	}
+	exe, err := append_bundle(interpreter, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// bundling a bundled executable replaces its bundle
+	b.Main = "lib.min"
+	if exe, err = append_bundle(exe, b); err != nil {
+		t.Fatal(err)
+	}
+	if size := interpreter_size(exe); size != len(interpreter) {
+		t.Errorf("the interpreter is %d bytes long, want %d", size, len(interpreter))
+	}
+	path := filepath.Join(t.TempDir(), "app")
+	if err := os.WriteFile(path, exe, 0755); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := read_bundle(path)
+	if !ok {
+		t.Fatal("the bundle was not found")
+	}
+	if !reflect.DeepEqual(got, b) {
+		t.Errorf("read back %+v, want %+v", got, b)
+	}
+	if _, err := bytecode.Decode(got.Files[got.Main]); err != nil {
+		t.Error(err)
+	}
+	if err := os.WriteFile(path, interpreter, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := read_bundle(path); ok {
+		t.Error("a bundle was read from a plain executable")
+	}
+	if err := os.WriteFile(path, exe[:len(exe)-1], 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := read_bundle(path); ok {
+		t.Error("a bundle was read from a cut executable")
+	}
+}
@@ -0,0 +1,267 @@
+package bytecode
+
+// AST START
+
+// A statement is parsed into a tree of the nodes below, which the lowering
+// pass then turns into actions. The grammar, loosest binding first:
+//
+//	statement = [name ["=" | "&="]] "$" command
+//	          | header ":"
+//	          | "return" [expr {"," expr}]
+//	          | "import" string "as" name
+//	          | name ("++" | "--")
+//	          | "..."
+//	          | target {"," target} [modifier] ("=" | "&=") expr
+//	          | expr
+//	header    = ("if" | "while" | "repeat") expr
+//	          | ("switch" | "case") [expr]
+//	          | "else" | "defer"
+//	          | "func" name [param {"," param}]
+//	          | "struct" name [member {"," member}]
+//	          | "for" expr "->" name {"," expr "->" name}
+//	          | "process" [item {"," item}]          item = name | name "->" name
+//	          | "pool" flow {"," flow}               flow = name ("->" | "<-") name
+//	          | "error" [(name | string) {"," (name | string)}]
+//	target    = name {"'" index | "." field}
+//	modifier  = "+" | "-" | "*" | "/" | "//" | "%" | "^"
+//	expr      = logic
+//	logic     = compare {("and" | "or") compare}
+//	compare   = sum {("==" | "!=" | "<" | ">") sum}
+//	sum       = product {("+" | "-") product}
+//	product   = unary {("*" | "/" | "//" | "%") unary}
+//	unary     = ("-" | "+" | "not") unary | power
+//	power     = postfix {"^" signed}
+//	postfix   = primary {"'" index | "." field | "." list}
+//	index     = ("-" | "+" | "not") index | primary
+//	signed    = ("-" | "+" | "not") signed | postfix
+//	field     = name | integer
+//	primary   = name | constant | "(" expr ")" | list | pair | call | lambda
+//	list      = "[" [expr {"," expr} [","]] "]"
+//	pair      = "{" [expr ":" expr {"," expr ":" expr} [","]] "}"
+//	call      = "!" postfix [arg {"," arg}]
+//	arg       = [name "="] expr
+//	lambda    = "func" [param {"," param}] ":" expr
+//	param     = name ["=" expr] | "..." name
+//	member    = name [type] ["=" expr]              type = name
+//
+// Every binary operator is left associative, `2^3^2` included, and operands
+// are evaluated left to right. A call takes the expressions up to the end of
+// its brackets or statement as arguments: `!f x + 1` calls f with x + 1 and
+// `a + !f x, y` adds a to f(x, y). In lists, pairs and return values commas
+// separate the items first, so there a call takes a single argument. A
+// `type.[...]` list is an array of that type, `x.name` indexes x with the
+// string "name", and `a += b` stands for `a = a + (b)`. A lambda returns its
+// expression, which ends at the first comma outside of brackets: a call of
+// several arguments goes in parentheses, `func x: (!f x, 1)`. So does a
+// call in the default value of a parameter, `name=expr`, and named arguments
+// come after the positional ones. The block of a struct holds the func
+// statements of its methods.
+
+// Node is any node of the tree; Pos is the token it starts at.
+type Node interface {
+	Pos() Token
+}
+
+// Expr is a node that has a value.
+type Expr interface {
+	Node
+	expr()
+}
+
+// Stmt is a node for a whole statement.
+type Stmt interface {
+	Node
+	stmt()
+}
+
+type (
+	// Ident is a variable or function name.
+	Ident struct {
+		Name Token
+	}
+
+	// ConstLit is a number, string, bool or byte literal.
+	ConstLit struct {
+		Value Token
+	}
+
+	// ListLit is a list literal, or an array one when Type names its element type.
+	ListLit struct {
+		Type  Token // the WORD before ".[", Type.Value is "" for a list
+		Open  Token
+		Items []Expr
+	}
+
+	// PairLit is a pair literal, Keys[n] mapping to Values[n].
+	PairLit struct {
+		Open   Token
+		Keys   []Expr
+		Values []Expr
+	}
+
+	// ParenExpr is an expression in parentheses.
+	ParenExpr struct {
+		Open Token
+		X    Expr
+	}
+
+	// UnaryExpr is `-x`, `+x` or `not x`.
+	UnaryExpr struct {
+		Op Token
+		X  Expr
+	}
+
+	// BinaryExpr is an arithmetic, comparison or logic operation.
+	BinaryExpr struct {
+		Op Token
+		X  Expr
+		Y  Expr
+	}
+
+	// IndexExpr is `x'i`, or `x.name` with Index the string constant "name".
+	IndexExpr struct {
+		Op    Token
+		X     Expr
+		Index Expr
+	}
+
+	// CallExpr is `!fun args`.
+	CallExpr struct {
+		Bang  Token
+		Fun   Expr
+		Args  []Expr
+		Names []Token // Names[n] passes Args[n] by name, Value "" for a positional one
+	}
+
+	// FuncLit is an anonymous function, `func x, y: x + y`.
+	FuncLit struct {
+		Func   Token
+		Params []ParamDecl
+		Body   Expr
+	}
+)
+
+type (
+	// ExprStmt is an expression run for its effects, usually a call.
+	ExprStmt struct {
+		X Expr
+	}
+
+	// AssignStmt stores Value in its targets, indexing it when there are several.
+	AssignStmt struct {
+		Targets  []Expr // Ident or IndexExpr nodes rooted at an Ident
+		Modifier Token  // the operator of `a += b`, Type "" for a plain assignment
+		Op       Token  // EQ, or PEQ for `&=`
+		Value    Expr
+	}
+
+	// ShellStmt runs the source line as a command, see the $ action.
+	ShellStmt struct {
+		Dollar Token
+		Target Token // the variable of `out = $ ...`, Value "" for none
+	}
+
+	// ReturnStmt ends the function with its values.
+	ReturnStmt struct {
+		Keyword Token
+		Values  []Expr
+	}
+
+	// IncDecStmt is `x++` or `x--`.
+	IncDecStmt struct {
+		Name Token
+		Op   Token
+	}
+
+	// ImportStmt binds the names a module exports to Name, as a pair.
+	ImportStmt struct {
+		Keyword Token
+		Path    *ConstLit
+		Name    Token
+	}
+
+	// PassStmt is the `...` placeholder statement.
+	PassStmt struct {
+		Dots Token
+	}
+
+	// HeaderStmt is a keyword statement opening an indented block.
+	HeaderStmt struct {
+		Keyword Token
+		Cond    Expr        // if, while, repeat, switch and case; nil when left out
+		Name    Token       // the function of func, the type of struct
+		Params  []ParamDecl // func parameters, struct fields
+		Names   []Token     // error variables and types, names process copies
+		Flows   []Flow      // for, process and pool
+		Node    string      // node of the block, "" when parsed on its own
+	}
+
+	// ParamDecl is a parameter of a func statement or a lambda, `name`,
+	// `name=default` or `...name` for the rest of the positional arguments,
+	// or a field of a struct, which may also declare its type, `x int`.
+	ParamDecl struct {
+		Dots    Token // TDOT of a rest parameter, Type "" otherwise
+		Name    Token
+		Type    Token // the type of a field, Type "" when it declares none
+		Default Expr  // nil when the parameter is required
+	}
+
+	// Flow is `from -> to` in a header, or `from <- to` in pool.
+	Flow struct {
+		From  Expr
+		Arrow Token
+		To    Token
+	}
+)
+
+func (e *Ident) Pos() Token      { return e.Name }
+func (e *ConstLit) Pos() Token   { return e.Value }
+func (e *ParenExpr) Pos() Token  { return e.Open }
+func (e *UnaryExpr) Pos() Token  { return e.Op }
+func (e *PairLit) Pos() Token    { return e.Open }
+func (e *CallExpr) Pos() Token   { return e.Bang }
+func (e *FuncLit) Pos() Token    { return e.Func }
+func (e *BinaryExpr) Pos() Token { return e.X.Pos() }
+func (e *IndexExpr) Pos() Token  { return e.X.Pos() }
+func (e *ListLit) Pos() Token {
+	if e.Type.Value != "" {
+		return e.Type
+	}
+	return e.Open
+}
+
+func (s *ExprStmt) Pos() Token   { return s.X.Pos() }
+func (s *AssignStmt) Pos() Token { return s.Targets[0].Pos() }
+func (s *ShellStmt) Pos() Token {
+	if s.Target.Value != "" {
+		return s.Target
+	}
+	return s.Dollar
+}
+func (s *ReturnStmt) Pos() Token { return s.Keyword }
+func (s *IncDecStmt) Pos() Token { return s.Name }
+func (s *PassStmt) Pos() Token   { return s.Dots }
+func (s *ImportStmt) Pos() Token { return s.Keyword }
+func (s *HeaderStmt) Pos() Token { return s.Keyword }
+
+func (*Ident) expr()      {}
+func (*ConstLit) expr()   {}
+func (*ListLit) expr()    {}
+func (*PairLit) expr()    {}
+func (*ParenExpr) expr()  {}
+func (*UnaryExpr) expr()  {}
+func (*BinaryExpr) expr() {}
+func (*IndexExpr) expr()  {}
+func (*CallExpr) expr()   {}
+func (*FuncLit) expr()    {}
+
+func (*ExprStmt) stmt()   {}
+func (*AssignStmt) stmt() {}
+func (*ShellStmt) stmt()  {}
+func (*ReturnStmt) stmt() {}
+func (*IncDecStmt) stmt() {}
+func (*PassStmt) stmt()   {}
+func (*ImportStmt) stmt() {}
+func (*HeaderStmt) stmt() {}
+
+// AST END
@@ -6,8 +6,10 @@ import (
 	"math/big"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"text/template"
 	"unicode/utf8"
 )
@@ -106,7 +108,28 @@ type RegTemp struct {
 
 func FillMinT(fname string) {
 	b, _ := os.ReadFile(fname)
-	entries := strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "#")
+	LoadMinT(string(b))
+}
+
+// LoadMinT registers the templates of a MinT file given as text. Each entry
+// starts with a "#", a "\#" stands for a "#" of its pattern or template.
+func LoadMinT(text string) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	entries := []string{}
+	b := strings.Builder{}
+	for i := 0; i < len(text); i++ {
+		switch {
+		case strings.HasPrefix(text[i:], `\#`):
+			b.WriteByte('#')
+			i++
+		case text[i] == '#':
+			entries = append(entries, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(text[i])
+		}
+	}
+	entries = append(entries, b.String())
 	for _, entry := range entries {
 		lines := strings.Split(entry, "\n")
 		if lines[0] == "" {
@@ -156,6 +179,8 @@ func (mp *MinPtr) String() string {
 type Token struct {
 	Type  string
 	Value string
+	Line  int // source line the token was written on, from 1, 0 for tokens made by the compiler
+	Col   int // column it starts at, from 1
 }
 
 func (t *Token) String() string {
@@ -181,115 +206,6 @@ func ToksMatch(tokens []Token, reg *regexp.Regexp) bool {
 	return reg.MatchString(identifier)
 }
 
-func keys_sorted(keys []string) bool {
-	for n := 1; n < len(keys); n++ {
-		if len(keys[n-1]) < len(keys[n]) {
-			return false
-		}
-	}
-	return true
-}
-
-func sort_keys(mkeys map[string]string) []string {
-	keys := []string{}
-	for key := range mkeys {
-		keys = append(keys, key)
-	}
-	for !keys_sorted(keys) {
-		for n := 1; n < len(keys); n++ {
-			if len(keys[n-1]) < len(keys[n]) {
-				keys[n], keys[n-1] = keys[n-1], keys[n]
-				break
-			}
-		}
-	}
-	return keys
-}
-
-func remove_strings(source string) (string, map[string]string) {
-	// TODO: fix bug where \n in string shifts indexes by 1
-	str_map := make(map[string]string)
-	parts := split_code(source)
-	parts_n := split_code(strings.ReplaceAll(source, "\\n", "/n"))
-	code_parts := []string{}
-	name_n := 0
-	for n := 0; n < len(parts); n++ {
-		if n%2 == 0 {
-			code_parts = append(code_parts, parts[n])
-		} else {
-			name := fmt.Sprintf("_str_%d", name_n)
-			name_n++
-			code_parts = append(code_parts, name)
-			if n < len(parts)-1 {
-				parts[n+1] = strings.Repeat("\n", strings.Count(parts_n[n], "\n")) + parts[n+1] // TODO: evaluate behavior ###
-			}
-			str_map[name] = parts[n]
-			//println(parts[n])
-		}
-	}
-	return strings.Join(code_parts, "\""), str_map
-}
-
-func remove_comment(str string) string {
-	splitted := strings.Split(str, "\n")
-	for n := 0; n < len(splitted); n++ {
-		splitted[n] = strings.Split(splitted[n], "#")[0]
-	}
-	return strings.Join(splitted, "\n")
-}
-
-func split_code(code string) []string {
-	splitted := []string{""}
-	for len(code) > 0 {
-		//fmt.Println(code)
-		switch {
-		case strings.HasPrefix(code, "\\\""):
-			splitted[len(splitted)-1] += "\""
-			code = code[2:]
-		case strings.HasPrefix(code, "\\\\"):
-			splitted[len(splitted)-1] += "\\"
-			code = code[2:]
-		case strings.HasPrefix(code, "\\\\n"):
-			splitted[len(splitted)-1] += "\\n"
-			code = code[3:]
-		case strings.HasPrefix(code, "\\n"):
-			splitted[len(splitted)-1] += "\n"
-			code = code[2:]
-		case strings.HasPrefix(code, "\\\\r"):
-			splitted[len(splitted)-1] += "\\r"
-			code = code[3:]
-		case strings.HasPrefix(code, "\\r"):
-			splitted[len(splitted)-1] += "\r"
-			code = code[2:]
-		case strings.HasPrefix(code, "\""):
-			splitted = append(splitted, "")
-			code = code[1:]
-		default:
-			r, size := utf8.DecodeRuneInString(code)
-			splitted[len(splitted)-1] += string(r) //code[0:1]
-			code = code[size:]
-		}
-	}
-	return splitted
-}
-func split_code_n(code string) []string {
-	splitted := []string{""}
-	for len(code) > 0 {
-		switch {
-		case strings.HasPrefix(code, "\\\""):
-			splitted[len(splitted)-1] += "\""
-			code = code[2:]
-		case strings.HasPrefix(code, "\""):
-			splitted = append(splitted, "")
-			code = code[1:]
-		default:
-			r, size := utf8.DecodeRuneInString(code)
-			splitted[len(splitted)-1] += string(r) //code[0:1]
-			code = code[size:]
-		}
-	}
-	return splitted
-}
 func DotConst(tokens []Token) []Token {
 	reg_const := regexp.MustCompile(`^(-?[0-9]+)$`)
 	points := []int{}
@@ -304,55 +220,17 @@ func DotConst(tokens []Token) []Token {
 	}
 	for _, ind := range reversed {
 		left, right := ind-1, ind+1
-		if tokens[left].Type == "CONST" && reg_const.MatchString(tokens[left].Value) && tokens[right].Type == "CONST" && reg_const.MatchString(tokens[right].Value) {
-			tokens2 := tokens[:left]
-			tokens2 = append(tokens2, Token{"CONST", tokens[left].Value + "." + tokens[right].Value})
-			tokens2 = append(tokens2, tokens[right+1:]...)
-			tokens = Unlink(tokens2)
-		} else if tokens[left].Type == "WORD" && tokens[left].Value == "b" && tokens[right].Type == "CONST" && reg_const.MatchString(tokens[right].Value) {
-			tokens2 := tokens[:left]
-			tokens2 = append(tokens2, Token{"CONST", tokens[left].Value + "." + tokens[right].Value})
-			tokens2 = append(tokens2, tokens[right+1:]...)
-			tokens = Unlink(tokens2)
-		}
-	}
-	return tokens
-}
-
-func oop(tokens []Token) []Token {
-	if Has(tokens, Token{"DOT", ""}) {
-		ind := 0
-		for n := range len(tokens) {
-			if tokens[n].Type == "DOT" && tokens[n-1].Type == "WORD" {
-				ind = n
-				break
-			}
-		}
-		tokens[ind] = Token{"SUB", ""}
-		tokens[ind+1] = Token{"CONST", "\"" + tokens[ind+1].Value + "\""}
-	}
-	return Unlink(tokens)
-	reg_const := regexp.MustCompile(`^(-?[0-9]+)$`)
-	points := []int{}
-	for n := 0; n < len(tokens); n++ {
-		if tokens[n].Type == "DOT" {
-			points = append(points, n)
+		if left < 0 || right >= len(tokens) {
+			continue
 		}
-	}
-	reversed := []int{}
-	for n := len(points) - 1; n > -1; n-- {
-		reversed = append(reversed, points[n])
-	}
-	for _, ind := range reversed {
-		left, right := ind-1, ind+1
 		if tokens[left].Type == "CONST" && reg_const.MatchString(tokens[left].Value) && tokens[right].Type == "CONST" && reg_const.MatchString(tokens[right].Value) {
 			tokens2 := tokens[:left]
-			tokens2 = append(tokens2, Token{"CONST", tokens[left].Value + "." + tokens[right].Value})
+			tokens2 = append(tokens2, tokens[left].As("CONST", tokens[left].Value+"."+tokens[right].Value))
 			tokens2 = append(tokens2, tokens[right+1:]...)
 			tokens = Unlink(tokens2)
 		} else if tokens[left].Type == "WORD" && tokens[left].Value == "b" && tokens[right].Type == "CONST" && reg_const.MatchString(tokens[right].Value) {
 			tokens2 := tokens[:left]
-			tokens2 = append(tokens2, Token{"CONST", tokens[left].Value + "." + tokens[right].Value})
+			tokens2 = append(tokens2, tokens[left].As("CONST", tokens[left].Value+"."+tokens[right].Value))
 			tokens2 = append(tokens2, tokens[right+1:]...)
 			tokens = Unlink(tokens2)
 		}
@@ -360,88 +238,8 @@ func oop(tokens []Token) []Token {
 	return tokens
 }
 
-func Tokenize(sourcestr string) []Token {
-	reg_const := regexp.MustCompile(`^(true|false|(-?[0-9]+\.[0-9]+)|(-?[0-9]+)|(b\.[0-9]+)|".*")$`)
-	constants := map[string]string{"$": "DOLL", ",": "COMM", ".": "DOT", "'": "SUB", " or ": "OR", " and ": "AND", "not ": "NOT", ":": "COL", "}": "C_CUR", "{": "O_CUR", "]": "C_BR", "[": "O_BR", ")": "C_PAR", "(": "O_PAR", "!": "ACT", "++": "PP", "--": "MM", "+": "PLUS", "->": "R_ARR", "-": "MINUS", "*": "MUL", "//": "DDIV", "/": "DIV", "^": "POW", "%": "MOD", "<": "LESS", ">": "GREAT", "==": "ISEQ", "!=": "NISEQ", "<-": "L_ARR", "&=": "PEQ", "=": "EQ", "...": "TDOT"}
-	focus := 0
-	output := []Token{}
-	buffer := ""
-	source := []string{}
-	for _, char := range sourcestr {
-		source = append(source, string(char))
-	}
-	for focus < len(source) {
-		changed := false
-		for _, key := range sort_keys(constants) {
-			if strings.HasPrefix(strings.Join(source[focus:], ""), key) {
-				if buffer != "" {
-					buffer = strings.TrimSpace(buffer)
-					if reg_const.MatchString(buffer) {
-						output = append(output, Token{"CONST", buffer})
-					} else {
-						output = append(output, Token{"WORD", buffer})
-					}
-					buffer = ""
-				}
-				output = append(output, Token{constants[key], ""})
-				focus += len(key)
-				changed = true
-				break
-			}
-		}
-		if !changed {
-			if source[focus] == " " {
-				if buffer != "" {
-					buffer = strings.TrimSpace(buffer)
-					if reg_const.MatchString(buffer) {
-						output = append(output, Token{"CONST", buffer})
-					} else {
-						output = append(output, Token{"WORD", buffer})
-					}
-					buffer = ""
-				}
-				focus++
-				continue
-			}
-			buffer += string(source[focus])
-			focus++
-		}
-	}
-	if buffer != "" {
-		buffer = strings.TrimSpace(buffer)
-		if reg_const.MatchString(buffer) {
-			output = append(output, Token{"CONST", buffer})
-		} else {
-			output = append(output, Token{"WORD", buffer})
-		}
-		buffer = ""
-	}
-	output = unary(output)
-	output = DotConst(output)
-	output = oop(output)
-	return output
-}
-
-func unary(tokens []Token) []Token {
-	ops := []string{"MINUS", "NOT"}
-	for n := 1; n < len(tokens); n++ {
-		if !Has([]string{"WORD", "CONST", "C_PAR"}, tokens[n-1].Type) && Has(ops, tokens[n].Type) {
-			t := Unlink(tokens[:n])
-			t = append(t, Token{"O_PAR", ""})
-			t = append(t, Token{"CONST", "0"})
-			t = append(t, tokens[n:n+2]...)
-			t = append(t, Token{"C_PAR", ""})
-			t = append(t, tokens[n+2:]...)
-			tokens = Unlink(t)
-			n = 0
-			continue
-		}
-	}
-	return tokens
-}
-
 func Test() {
-	c := GetCode("if true:\n  a = !len my_list, 11\nelse:\n  !(obj.fun) \"xd\"\n$\"ls\"\n!print !len my_list")
+	c := GetCode("if true:\n  a = !len my_list, 11\nelse:\n  !(obj.fun) \"xd\"\n$\"ls\"\n!print !len my_list").Code
 	for key := range c {
 		acts := c[key]
 		fmt.Println(key + ":")
@@ -476,6 +274,48 @@ type Action struct {
 	Type      string
 	Variables []Variable
 	Source    *SourceLine
+	Value     any     // the parsed literal of a const action, see Constant
+	Frame     *Frame  // the layout of the scope running the action, see resolveFrames
+	Syms      []int32 // the symbols of the target and the variables in Frame
+}
+
+var (
+	reg_const_int   = regexp.MustCompile(`^-?[0-9]+$`)
+	reg_const_float = regexp.MustCompile(`^-?[0-9]+\.[0-9]+$`)
+	reg_const_byte  = regexp.MustCompile(`^-?b\.[0-9]+$`)
+)
+
+// Constant parses the literal of a const action into a *big.Int, *big.Float,
+// byte, bool or string. Numbers are shared by every run of the action, so
+// they have to be copied before being stored.
+func Constant(v Variable) any {
+	literal := string(v)
+	switch {
+	case reg_const_float.MatchString(literal):
+		b := big.NewFloat(0)
+		b.SetString(literal)
+		return b
+	case reg_const_byte.MatchString(literal):
+		i64, _ := strconv.ParseInt(literal[2:], 10, 64)
+		return byte(i64)
+	case reg_const_int.MatchString(literal):
+		b := big.NewInt(0)
+		b.SetString(literal, 10)
+		return b
+	case literal == "true" || literal == "false":
+		return literal == "true"
+	}
+	return literal[1 : len(literal)-1]
+}
+
+// precompile parses the literals of the const actions once, instead of on
+// every run.
+func precompile(acts []Action) {
+	for n := range acts {
+		if acts[n].Type == "const" && len(acts[n].Variables) == 1 {
+			acts[n].Value = Constant(acts[n].Variables[0])
+		}
+	}
 }
 
 func (a *Action) First() string {
@@ -503,7 +343,7 @@ func (a *Action) Parse(s string) {
 	s, s_source = strings.SplitN(s, ";", 2)[0], strings.SplitN(s, ";", 2)[1]
 	n_source, _ := strconv.Atoi(strings.SplitN(s_source, ",", 2)[0])
 	str_source := strings.SplitN(s_source, ",", 2)[1]
-	sl := SourceLine{str_source, n_source}
+	sl := SourceLine{Source: str_source, N: n_source}
 	splitted := strings.Split(s[len("action:"):], ",")
 	a.Target = splitted[0]
 	a.Type = splitted[1]
@@ -512,926 +352,209 @@ func (a *Action) Parse(s string) {
 		a.Variables = append(a.Variables, Variable(vs))
 	}
 	a.Source = &sl
-}
-
-var TempN int
-
-func HasAct(tokens []Token) int {
-	ind := -1
-	for n := len(tokens) - 1; n >= 0; n-- {
-		if tokens[n].Type == "ACT" {
-			ind = n
-		}
-	}
-	return ind
-}
-
-func HasActLeft(tokens []Token) int {
-	ind := -1
-	for n := len(tokens) - 1; n >= 0; n-- {
-		if tokens[n].Type == "ACT" {
-			ind = n
-			break
-		}
+	if a.Type == "const" && len(a.Variables) == 1 {
+		a.Value = Constant(a.Variables[0])
 	}
-	return ind
 }
 
-func HasOps(tokens []Token, ops []string) bool {
-	for _, token := range tokens {
-		if Has(ops, token.Type) {
-			return true
-		}
-	}
-	return false
+// HasTok reports whether a token of the type is among tokens, wherever it
+// was written.
+func HasTok(tokens []Token, typ string) bool {
+	return IndexTok(tokens, typ) > -1
 }
 
-func GetOp(tokens []Token) int {
-	ops := [][]string{{"NOT"}, {"SUB", "DOT"}, {"POW"}, {"MUL", "DIV", "DDIV", "MOD"}, {"PLUS", "MINUS"}, {"ISEQ", "NISEQ", "LESS", "GREAT"}, {"OR", "AND"}}
-	for _, level := range ops {
-		for n, token := range tokens {
-			for _, op := range level {
-				if op == token.Type {
-					return n
-				}
-			}
+// IndexTok returns the index of the first token of the type, -1 if none.
+func IndexTok(tokens []Token, typ string) int {
+	for n, token := range tokens {
+		if token.Type == typ {
+			return n
 		}
 	}
 	return -1
 }
 
-func FixMinusPrefix(tokens *[]Token) {
-	if len(*tokens) > 0 {
-		if (*tokens)[0].Type == "MINUS" || (*tokens)[0].Type == "PLUS" {
-			/*
-				*tokens = (*tokens)[1:]
-				(*tokens)[0].Value = "-" + (*tokens)[0].Value
-			*/
-			(*tokens) = append([]Token{{"CONST", "0"}}, (*tokens)...)
-		}
-	}
+func (c *Compiler) TempName() string {
+	name := fmt.Sprintf("_temp_%d", c.tempN)
+	c.tempN++
+	return name
 }
 
-func TempName() string {
-	name := fmt.Sprintf("_temp_%d", TempN)
-	TempN++
+// NodeName gives the next node name of the unit, prefixed with the unit
+// number so nodes of different compilations never collide in a shared Code map.
+func (c *Compiler) NodeName() string {
+	name := fmt.Sprintf("_node_%d_%d", c.unit, c.nodeN)
+	c.nodeN++
 	return name
 }
 
 type SourceLine struct {
 	Source string
 	N      int
+	Col    int // column where the statement starts, 0 when unknown
 }
 
-func CommaArgs(tokens []Token) [][]Token {
-	var args [][]Token
-	var buffer []Token
-	level := 0
-	// "O_CUR", "]": "C_BR", "[": "O_BR", ")": "C_PAR"
-	for _, tok := range tokens {
-		if tok.Type == "COMM" && level == 0 {
-			args = append(args, Unlink(buffer))
-			buffer = []Token{}
-		} else if tok.Type == "O_PAR" || tok.Type == "O_CUR" || tok.Type == "O_BR" {
-			level++
-			buffer = append(buffer, tok)
-		} else if tok.Type == "C_PAR" || tok.Type == "C_CUR" || tok.Type == "C_BR" {
-			level--
-			buffer = append(buffer, tok)
-		} else {
-			buffer = append(buffer, tok)
-		}
-	}
-	if len(buffer) > 0 {
-		args = append(args, Unlink(buffer))
-		buffer = []Token{}
-	}
-	return args
-}
-
-func HasParen(tokens []Token) bool {
-	for n := 0; n < len(tokens); n++ {
-		if tokens[n].Type == "O_PAR" || tokens[n].Type == "C_PAR" {
-			return true
-		}
-	}
-	return false
-}
-
-func HasCur(tokens []Token) bool {
-	for n := 0; n < len(tokens); n++ {
-		if tokens[n].Type == "O_CUR" || tokens[n].Type == "C_CUR" {
-			return true
-		}
-	}
-	return false
-}
-
-func HasParenWhere(tokens []Token) (int, int) {
-	for n := len(tokens) - 1; n >= 0; n-- {
-		if tokens[n].Type == "C_PAR" {
-			end := n
-			for m := end; m >= 0; m-- {
-				if tokens[m].Type == "O_PAR" {
-					start := m
-					return start, end
-				}
-			}
-		}
-	}
-	return -1, len(tokens)
+type CodePart struct {
+	Line        string
+	LineOG      string
+	Indentation int
+	N           int
+	TargetNode  string
+	Lines       []int  // source line of each line of the statement, from 0
+	Doc         string // the ## comment lines right above the statement
+	Indents     []int  // indentation of each line of the statement
 }
 
-func HasParenWhereOuter(tokens []Token) (int, int) {
-	start := 0
-	for tokens[start].Type != "O_PAR" {
-		start++
-	}
-	end := start + 1
-	level := 0
-	for end < len(tokens) {
-		if tokens[end].Type == "C_PAR" && level == 0 {
-			break
-		}
-		if tokens[end].Type == "O_PAR" {
-			level++
-		} else if tokens[end].Type == "C_PAR" {
-			level--
+func GetInd(line string) int {
+	for n := 0; n < len(line); n++ {
+		if line[n] != ' ' {
+			return n
 		}
-		end++
 	}
-	return start, end
+	return 0
 }
 
-func HasCurWhereOuter(tokens []Token) (int, int) {
-	start := 0
-	for tokens[start].Type != "O_CUR" {
-		start++
-	}
-	end := start + 1
-	level := 0
-	for end < len(tokens) {
-		if tokens[end].Type == "C_CUR" && level == 0 {
-			break
-		}
-		if tokens[end].Type == "O_CUR" {
-			level++
-		} else if tokens[end].Type == "C_CUR" {
-			level--
-		}
-		end++
+func trim_all(strs []string) []string {
+	for n := 0; n < len(strs); n++ {
+		strs[n] = strings.TrimSpace(strs[n])
 	}
-	return start, end
+	return strs
 }
 
-func ParenArgs(tokens []Token, actions []Action, sl *SourceLine) ([]Token, []Action) {
-	start := HasAct(tokens)
-	if tokens[start+1].Type == "O_PAR" {
-		level := 0
-		for n := start; n < len(tokens); n++ {
-			if tokens[n].Type == "O_PAR" {
-				level++
-			} else if tokens[n].Type == "C_PAR" {
-				if level == 0 {
-					args := CommaArgs(tokens[n+1:])
-					vs := []Variable{}
-					for _, arg := range args {
-						actionslet := GetActs(arg, sl)
-						t := Variable(actionslet[len(actionslet)-1].Target)
-						vs = append(vs, t)
-						actions = append(actions, actionslet...)
-					}
-					actlet := GetActs(tokens[start+2:n], sl)
-					targ := TempName()
-					actions = append(actions, Action{targ, actlet[len(actlet)-1].Target, vs, sl})
-					tokens = []Token{{"WORD", targ}}
-					return tokens, actions
-				}
-				level--
-			}
-		}
-	}
-	return []Token{}, []Action{}
+// Unit is the result of compiling one source: its nodes and the node that
+// runs the top level.
+type Unit struct {
+	Entry string
+	Code  map[string][]Action
 }
 
-func HasList(tokens []Token) bool {
-	for n := 0; n < len(tokens); n++ {
-		if tokens[n].Type == "O_BR" {
-			return true
-		}
-	}
-	return false
-}
+var unitN atomic.Uint64
 
-func HasListWhere(tokens []Token) (int, int) {
-	for n := 0; n < len(tokens); n++ {
-		if tokens[n].Type == "O_BR" {
-			level := 0
-			for m := n; m < len(tokens); m++ {
-				switch {
-				case tokens[m].Type == "O_BR":
-					level++
-				case tokens[m].Type == "C_BR":
-					level--
-				}
-				if level == 0 && tokens[m].Type == "C_BR" {
-					return n, m
-				}
-			}
-		}
-	}
-	return -1, -1
+// Compiler holds the naming state of a single compilation, which makes GetCode
+// safe to call from several goroutines at once.
+type Compiler struct {
+	unit    uint64
+	nodeN   int
+	tempN   int
+	lambdas map[string][]Action // nodes of the lambda bodies and default values, by name
 }
 
-func GetTargetAuto(tokens []Token, actions *[]Action, sl *SourceLine) string {
-	actlet := GetActs(tokens, sl)
-	var targ string
-	if len(actlet) < 1 {
-		name := tokens[0].Value
-		if tokens[0].Type == "CONST" {
-			name = TempName()
-			actlet = append(actlet, Action{name, "const", []Variable{Variable(tokens[0].Value)}, sl})
-		}
-		targ = name
-	} else {
-		targ = actlet[len(actlet)-1].Target
-	}
-	*actions = append(*actions, actlet...)
-	return targ
+// SyntaxError is a mistake in a source that keeps it from compiling.
+type SyntaxError struct {
+	Line    int // from 1
+	Column  int // from 1
+	Message string
+	Source  string // the source line
 }
 
-func ModifierModifier(tokens []Token, ops []string) []Token {
-	// a += 3 => a = a + 3
-	eq_id := -1
-	for n, tok := range tokens {
-		if tok.Type == "EQ" || tok.Type == "PEQ" {
-			eq_id = n
-			break
-		}
-	}
-	if eq_id > -1 && HasOps(tokens[eq_id-1:eq_id], ops) {
-		modifier := tokens[eq_id-1]
-		targ := Unlink(tokens[:eq_id-1])
-		equals := tokens[eq_id]
-		tail := Unlink(tokens[eq_id+1:])
-		tokens_new := Unlink(tokens[:eq_id-1])
-		tokens_new = append(tokens_new, equals)
-		tokens_new = append(tokens_new, targ...)
-		tokens_new = append(tokens_new, modifier)
-		tokens_new = append(tokens_new, tail...)
-		tokens = Unlink(tokens_new)
-	}
-	return tokens
+func (e SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
 }
 
-func GetActs(tokens []Token, sl *SourceLine) []Action {
-	FixMinusPrefix(&tokens)
-	var actions []Action
-	ops := []string{"DOT", "SUB", "AND", "OR", "NOT", "PLUS", "MINUS", "MUL", "DIV", "DDIV", "MOD", "POW", "ISEQ", "NISEQ", "LESS", "GREAT"}
-	action_map := map[string]string{"DOT": ".", "SUB": "'", "AND": "and", "OR": "or", "NOT": "not", "ISEQ": "==", "NISEQ": "!=", "LESS": "<", "GREAT": ">", "PLUS": "+", "MINUS": "-", "MUL": "*", "DIV": "/", "DDIV": "//", "MOD": "%", "POW": "^"}
-	// TempN = 0 // might be unnecessary
-	tokens = ModifierModifier(tokens, ops)
-
-	// finding targets start
-	var targets []string
-	pointer := false
-	eq_id := -1
-	for n, tok := range tokens {
-		if tok.Type == "EQ" || tok.Type == "PEQ" {
-			if tok.Type == "PEQ" {
-				pointer = true
-			}
-			eq_id = n
-			break
-		}
-	}
-	var targets_tok [][]Token
-	if eq_id > -1 {
-		if len(tokens) > 0 && Has(tokens, Token{"DOLL", ""}) { // if it's a console call (with assignment)
-			dind := Index(tokens, Token{"DOLL", ""})
-			if Has(tokens[:dind], Token{"EQ", ""}) || Has(tokens[:dind], Token{"PEQ", ""}) {
-				targets = append(targets, tokens[0].Value)
-				tokens = Unlink(tokens[dind:])
-			}
-		} else { // if it's anything else
-			targets_tok = CommaArgs(tokens[:eq_id])
-			for _, targ_tok := range targets_tok {
-				if len(targ_tok) == 1 { // if it is a single var name
-					targets = append(targets, targ_tok[0].Value)
-				} else {
-					// TODO: make less hacky
-					targets = append(targets, targ_tok[0].Value)
-				}
-			}
-			tokens = Unlink(tokens[eq_id+1:])
-		}
-	}
-	// finding targets end
-	done := false
-	for !done {
-		switch {
-		case strings.HasPrefix(strings.TrimSpace(sl.Source), "$") || len(tokens) > 0 && Has(tokens, Token{"DOLL", ""}):
-			actions = append(actions, Action{TempName(), "$", []Variable{}, sl})
-			if len(targets) > 0 { // used to be `len(targets) > 0`
-				actions[len(actions)-1].Type = "$$"
-				actions[len(actions)-1].Target = targets[0]
-			}
-			tokens = []Token{}
-			return actions
-			/*
-				actlet := GetActs(tokens[1:], sl)
-				var t string
-				if len(actlet) > 0 {
-					t = actlet[len(actlet)-1].Target
-				} else {
-					if tokens[1].Type == "WORD" {
-						t = tokens[1].Value
-					} else {
-						v := Variable(tokens[1].Value)
-						temp := TempName()
-						actions = append(actions, Action{temp, "const", []Variable{v}, sl})
-						t = temp
-					}
-				}
-				actions = append(actions, actlet...)
-				actions = append(actions, Action{TempName(), "$", []Variable{Variable(t)}, sl})
-				tokens = []Token{{"WORD", t}}
-			*/
-		case len(tokens) > 1 && tokens[0].Type == "WORD" && tokens[0].Value == "repeat" && tokens[len(tokens)-1].Type == "LINK" && tokens[len(tokens)-2].Type == "COL":
-			actlet := GetActs(tokens[1:len(tokens)-2], sl)
-			var t string
-			if len(actlet) > 0 {
-				t = actlet[len(actlet)-1].Target
-			} else {
-				if tokens[1].Type == "WORD" {
-					t = tokens[1].Value
-				} else {
-					v := Variable(tokens[1].Value)
-					temp := TempName()
-					actions = append(actions, Action{temp, "const", []Variable{v}, sl})
-					t = temp
-				}
-			}
-			actions = append(actions, actlet...)
-			actions = append(actions, Action{tokens[len(tokens)-1].Value, "repeat", []Variable{Variable(t)}, sl})
-			tokens = []Token{{"WORD", t}}
-		case len(tokens) > 1 && tokens[0].Type == "WORD" && tokens[0].Value == "process" && tokens[len(tokens)-1].Type == "LINK" && tokens[len(tokens)-2].Type == "COL":
-			args := CommaArgs(tokens[1 : len(tokens)-2])
-			if len(args) > 0 && Has(args[0], Token{"R_ARR", ""}) {
-				// in case the right arrow is present
-				var arg []Token
-				for n, alet := range args {
-					if len(alet) == 3 && alet[1].Type == "R_ARR" {
-						arg = alet
-						args = append(args[:n], args[n+1:]...)
-						break
-					}
-				}
-				frozen := []Variable{}
-				for _, a := range args {
-					frozen = append(frozen, Variable(a[0].Value))
-				}
-				ind := Index(arg, Token{"R_ARR", ""})
-				left, right := arg[:ind], arg[ind+1:]
-				targ := right[0].Value
-				targ_node := tokens[len(tokens)-1].Value
-				actions = append(actions, Action{targ_node, "process", append([]Variable{Variable(left[0].Value), Variable(targ)}, frozen...), sl})
-				tokens = []Token{{"WORD", targ}}
-			} else {
-				// when the process just needs to start
-				// Nothing is copied into Nothing in here
-				frozen := []Variable{}
-				for _, a := range args {
-					frozen = append(frozen, Variable(a[0].Value))
-				}
-				targ := "Nothing"
-				targ_node := tokens[len(tokens)-1].Value
-				actions = append(actions, Action{targ_node, "process", append([]Variable{Variable("Nothing"), Variable(targ)}, frozen...), sl})
-				tokens = []Token{{"WORD", targ}}
-			}
-		case len(tokens) > 1 && tokens[0].Type == "WORD" && tokens[0].Value == "error" && tokens[len(tokens)-1].Type == "LINK" && tokens[len(tokens)-2].Type == "COL":
-			args := CommaArgs(tokens[1 : len(tokens)-2])
-			target := tokens[len(tokens)-1].Value
-			vs := []Variable{}
-			// TODO: add deep assignment support
-			switch len(args) {
-			case 1:
-				vs = append(vs, Variable(args[0][0].Value))
-			case 2:
-				vs = append(vs, Variable(args[0][0].Value))
-				vs = append(vs, Variable(args[1][0].Value))
-			}
-			actions = append(actions, Action{target, "error", vs, sl})
-			tokens = []Token{{"WORD", target}}
-		case len(tokens) > 1 && tokens[0].Type == "WORD" && tokens[0].Value == "if" && tokens[len(tokens)-1].Type == "LINK" && tokens[len(tokens)-2].Type == "COL":
-			actlet := GetActs(tokens[1:len(tokens)-2], sl)
-			var t string
-			if len(actlet) > 0 {
-				t = actlet[len(actlet)-1].Target
-			} else {
-				if tokens[1].Type == "WORD" {
-					t = tokens[1].Value
-				} else {
-					v := Variable(tokens[1].Value)
-					temp := TempName()
-					actions = append(actions, Action{temp, "const", []Variable{v}, sl})
-					t = temp
-				}
-			}
-			actions = append(actions, actlet...)
-			actions = append(actions, Action{tokens[len(tokens)-1].Value, "if", []Variable{Variable(t)}, sl})
-			// actions = append(actions, Action{"", "endif", []Variable{}, sl})
-			tokens = []Token{{"WORD", t}}
-		case len(tokens) > 1 && tokens[0].Type == "WORD" && tokens[0].Value == "while" && tokens[len(tokens)-1].Type == "LINK" && tokens[len(tokens)-2].Type == "COL":
-			actions = append(actions, Action{tokens[len(tokens)-1].Value, "while_start", []Variable{}, sl})
-			actlet := GetActs(tokens[1:len(tokens)-2], sl)
-			var t string
-			if len(actlet) > 0 {
-				t = actlet[len(actlet)-1].Target
-			} else {
-				if tokens[1].Type == "WORD" {
-					t = tokens[1].Value
-				} else {
-					v := Variable(tokens[1].Value)
-					temp := TempName()
-					actions = append(actions, Action{temp, "const", []Variable{v}, sl})
-					t = temp
-				}
-			}
-			actions = append(actions, actlet...)
-			actions = append(actions, Action{tokens[len(tokens)-1].Value, "while", []Variable{Variable(t)}, sl})
-			// actions = append(actions, Action{"", "endif", []Variable{}, sl})
-			tokens = []Token{{"WORD", t}}
-		case len(tokens) > 1 && tokens[0].Type == "WORD" && tokens[0].Value == "for" && tokens[len(tokens)-1].Type == "LINK" && tokens[len(tokens)-2].Type == "COL":
-			args_flat := tokens[1 : len(tokens)-2]
-			args := CommaArgs(args_flat)
-			vs := []Variable{}
-			for _, arg := range args {
-				ind := Index(arg, Token{"R_ARR", ""})
-				left, right := arg[:ind], arg[ind+1:]
-				actlet := GetActs(left, sl)
-				var t string
-				if len(actlet) == 0 {
-					name := left[0].Value
-					if left[0].Type == "CONST" {
-						name = TempName()
-						actions = append(actlet, Action{name, "const", []Variable{Variable(left[0].Value)}, sl})
-					}
-					//vs = append(vs, Variable(name))
-					t = name
-				} else {
-					actions = append(actions, actlet...)
-					t = actlet[len(actlet)-1].Target
-				}
-				vs = append(vs, Variable(t))
-				vs = append(vs, Variable(right[0].Value))
-			}
-			act := Action{tokens[len(tokens)-1].Value, "for", vs, sl}
-			actions = append(actions, act)
-			tokens = []Token{} // SUS, might cause errors due to length 0
-		case len(tokens) > 1 && tokens[0].Type == "WORD" && tokens[0].Value == "pool" && tokens[len(tokens)-1].Type == "LINK" && tokens[len(tokens)-2].Type == "COL":
-			right_arrows := []int{}
-			for n, token := range tokens {
-				if token.Type == "R_ARR" {
-					right_arrows = append(right_arrows, n)
-				}
-			}
-			left_arrows := []int{}
-			for n, token := range tokens {
-				if token.Type == "L_ARR" {
-					left_arrows = append(left_arrows, n)
-				}
-			}
-			vs := []Variable{}
-			for _, ra := range right_arrows {
-				vs = append(vs, Variable(tokens[ra-1].Value))
-				vs = append(vs, Variable(tokens[ra+1].Value))
-			}
-			vs = append(vs, Variable("Nothing"))
-			for _, la := range left_arrows {
-				vs = append(vs, Variable(tokens[la-1].Value))
-				vs = append(vs, Variable(tokens[la+1].Value))
-			}
-			actions = append(actions, Action{Target: tokens[len(tokens)-1].Value, Variables: vs, Type: "pool", Source: sl})
-			tokens = []Token{} // SUS, might cause errors due to length 0
-		case HasParen(tokens):
-			start, end := HasParenWhereOuter(tokens)
-			expr := tokens[start+1 : end]
-			actlet := GetActs(expr, sl)
-			var targ string
-			if len(actlet) < 1 {
-				name := expr[0].Value
-				if expr[0].Type == "CONST" {
-					name = TempName()
-					actlet = append(actlet, Action{name, "const", []Variable{Variable(expr[0].Value)}, sl})
-				}
-				targ = name
-			} else {
-				targ = actlet[len(actlet)-1].Target
-			}
-			actions = append(actions, actlet...)
-			newtok := tokens[:start]
-			newtok = append(newtok, Token{"WORD", targ})
-			newtok = append(newtok, tokens[end+1:]...)
-			tokens = Unlink(newtok)
-		case len(tokens) > 1 && HasCur(tokens):
-			start, end := HasCurWhereOuter(tokens)
-			args := CommaArgs(tokens[start+1 : end])
-			var targets []Variable
-			for _, arg := range args {
-				sep := 0
-				for arg[sep].Type != "COL" {
-					sep++
-				}
-				key_tok := arg[:sep]
-				val_tok := arg[sep+1:]
-				t0, t1 := GetTargetAuto(key_tok, &actions, sl), GetTargetAuto(val_tok, &actions, sl)
-				targets = append(targets, Variable(t0))
-				targets = append(targets, Variable(t1))
-			}
-			t := TempName()
-			a := Action{Target: t, Variables: targets, Type: "pair", Source: sl}
-			actions = append(actions, a)
-			tail := tokens[end+1:]
-			head := tokens[:start]
-			head = append(head, Token{"WORD", t})
-			head = append(head, tail...)
-			tokens = Unlink(head)
-		case len(tokens) > 1 && HasList(tokens):
-			is_array := false
-			atype := byte(NOTH)
-			arr_types := map[byte]string{0: "noth", 1: "int", 2: "float", 3: "str", 4: "arr", 5: "list", 6: "pair", 7: "bool", 8: "byte", 9: "func", 10: "id"}
-			start, end := HasListWhere(tokens)
-			if start-1 > -1 && tokens[start-1].Type == "DOT" {
-				t := tokens[start-2].Value
-				for key, val := range arr_types {
-					if val == t {
-						atype = key
-						is_array = true
-					}
-				}
-			}
-			args := CommaArgs(tokens[start+1 : end])
-			vs := []Variable{}
-			for _, arg := range args {
-				actionslet := GetActs(arg, sl)
-				var t Variable
-				if len(actionslet) > 0 {
-					t = Variable(actionslet[len(actionslet)-1].Target)
-				} else {
-					// TODO: add constant support
-					name := arg[0].Value
-					if arg[0].Type == "CONST" {
-						name = TempName()
-						actionslet = append(actionslet, Action{name, "const", []Variable{Variable(arg[0].Value)}, sl})
-					}
-					t = Variable(name)
-				}
-				vs = append(vs, t)
-				actions = append(actions, actionslet...)
-			}
-			targ := TempName()
-			if !is_array {
-				actions = append(actions, Action{targ, "list", vs, sl})
-				tail := Unlink(tokens[end+1:])
-				tokens = append(tokens[:start], []Token{{"WORD", targ}}...)
-				tokens = append(tokens, tail...)
-			} else {
-				type_name := TempName()
-				actions = append(actions, Action{type_name, "const", []Variable{Variable(fmt.Sprintf("b.%d", atype))}, sl})
-				actions = append(actions, Action{targ, "array", append([]Variable{Variable(type_name)}, vs...), sl})
-				tail := Unlink(tokens[end+1:])
-				tokens = append(tokens[:start-2], []Token{{"WORD", targ}}...)
-				tokens = append(tokens, tail...)
-			}
-		case len(tokens) > 1 && tokens[0].Type == "WORD" && tokens[0].Value == "func" && tokens[len(tokens)-1].Type == "LINK" && tokens[len(tokens)-2].Type == "COL":
-			func_name := tokens[1].Value
-			args := CommaArgs(tokens[2 : len(tokens)-2])
-			func_args := []Variable{Variable(func_name)}
-			for _, arg := range args {
-				func_args = append(func_args, Variable(arg[0].Value))
-			}
-			actions = append(actions, Action{tokens[len(tokens)-1].Value, "func", func_args, sl})
-			tokens = []Token{{"WORD", tokens[len(tokens)-1].Value}}
-		case len(tokens) > 1 && tokens[0].Type == "WORD" && tokens[0].Value == "switch" && tokens[len(tokens)-1].Type == "LINK" && tokens[len(tokens)-2].Type == "COL":
-			actlet := GetActs(tokens[1:len(tokens)-2], sl)
-			var t string
-			if len(actlet) > 0 {
-				t = actlet[len(actlet)-1].Target
-			} else {
-				if len(tokens) == 3 { // if argless switch
-					v := Variable("true")
-					temp := TempName()
-					actions = append(actions, Action{temp, "const", []Variable{v}, sl})
-					t = temp
-				} else if tokens[1].Type == "WORD" {
-					t = tokens[1].Value
-				} else {
-					v := Variable(tokens[1].Value)
-					temp := TempName()
-					actions = append(actions, Action{temp, "const", []Variable{v}, sl})
-					t = temp
-				}
-			}
-			actions = append(actions, actlet...)
-			actions = append(actions, Action{tokens[len(tokens)-1].Value, "switch", []Variable{Variable(t)}, sl})
-			tokens = []Token{{"WORD", t}}
-		case len(tokens) > 1 && tokens[0].Type == "WORD" && tokens[0].Value == "case" && tokens[len(tokens)-1].Type == "LINK" && tokens[len(tokens)-2].Type == "COL":
-			actlet := GetActs(tokens[1:len(tokens)-2], sl)
-			var t string
-			if len(actlet) > 0 {
-				t = actlet[len(actlet)-1].Target
-			} else {
-				if len(tokens) == 3 { // if argless switch
-					v := Variable("true")
-					temp := TempName()
-					actions = append(actions, Action{temp, "const", []Variable{v}, sl})
-					t = temp
-				} else if tokens[1].Type == "WORD" {
-					t = tokens[1].Value
-				} else {
-					v := Variable(tokens[1].Value)
-					temp := TempName()
-					actions = append(actions, Action{temp, "const", []Variable{v}, sl})
-					t = temp
-				}
-			}
-			actions = append(actions, actlet...)
-			actions = append(actions, Action{tokens[len(tokens)-1].Value, "case", []Variable{Variable(t)}, sl})
-			tokens = []Token{{"WORD", t}}
-		case len(tokens) > 1 && tokens[0].Type == "WORD" && tokens[0].Value == "else" && tokens[len(tokens)-1].Type == "LINK" && tokens[len(tokens)-2].Type == "COL":
-			actions = append(actions, Action{tokens[len(tokens)-1].Value, "else", []Variable{}, sl})
-			tokens = []Token{}
-		case len(tokens) > 0 && tokens[0].Type == "WORD" && tokens[0].Value == "return":
-			args := CommaArgs(tokens[1:])
-			vs := []Variable{}
-			for _, arg := range args {
-				actionslet := GetActs(arg, sl)
-				var t Variable
-				if len(actionslet) > 0 {
-					t = Variable(actionslet[len(actionslet)-1].Target)
-				} else {
-					// TODO: add constant support
-					name := arg[0].Value
-					if arg[0].Type == "CONST" {
-						name = TempName()
-						actionslet = append(actionslet, Action{name, "const", []Variable{Variable(arg[0].Value)}, sl})
-					}
-					t = Variable(name)
-				}
-				vs = append(vs, t)
-				actions = append(actions, actionslet...)
-			}
-			targ := TempName()
-			actions = append(actions, Action{targ, "return", vs, sl})
-			tokens = []Token{{"WORD", targ}} //append(tokens[:ind], []Token{{"WORD", targ}}...)
-		case len(tokens) == 1 && tokens[0].Type == "TDOT":
-			tokens = []Token{}
-		case len(tokens) == 2 && tokens[0].Type == "WORD" && (tokens[1].Type == "PP" || tokens[1].Type == "MM"):
-			t := tokens[0].Value
-			act := Action{t, ternary(tokens[1].Type == "PP", "++", "--"), []Variable{Variable(tokens[0].Value)}, sl}
-			actions = append(actions, act)
-			tokens = []Token{{"WORD", t}}
-		case HasOps(tokens, ops):
-			ind := GetOp(tokens)
-			var v0, v1 Variable
-			if tokens[ind-1].Type == "WORD" {
-				v0 = Variable(tokens[ind-1].Value)
-			} else {
-				vt := TempName()
-				actions = append(actions, Action{vt, "const", []Variable{Variable(tokens[ind-1].Value)}, sl})
-				v0 = Variable(vt)
-			}
-			if tokens[ind+1].Type == "WORD" {
-				v1 = Variable(tokens[ind+1].Value)
-			} else {
-				vt := TempName()
-				actions = append(actions, Action{vt, "const", []Variable{Variable(tokens[ind+1].Value)}, sl})
-				v1 = Variable(vt)
-			}
-			name := TempName()
-			actions = append(actions, Action{name, action_map[tokens[ind].Type], []Variable{v0, v1}, sl}) // TODO: actually add variables
-			tail := tokens[ind+2:]
-			tokens = append(tokens[:ind-1], Token{"WORD", name})
-			tokens = append(tokens, tail...)
-			tokens = Unlink(tokens)
-		case HasAct(tokens) > -1:
-			ind := HasActLeft(tokens)
-			action := tokens[ind+1]
-			args := CommaArgs(tokens[ind+2:])
-			vs := []Variable{}
-			for _, arg := range args {
-				actionslet := GetActs(arg, sl)
-				var t Variable
-				if len(actionslet) > 0 {
-					t = Variable(actionslet[len(actionslet)-1].Target)
-				} else {
-					// TODO: add constant support
-					name := arg[0].Value
-					if arg[0].Type == "CONST" {
-						name = TempName()
-						actionslet = append(actionslet, Action{name, "const", []Variable{Variable(arg[0].Value)}, sl})
-					}
-					t = Variable(name)
-				}
-				vs = append(vs, t)
-				actions = append(actions, actionslet...)
-			}
-			targ := TempName()
-			actions = append(actions, Action{targ, action.Value, vs, sl})
-			tokens = append(tokens[:ind], []Token{{"WORD", targ}}...)
-		default:
-			done = true
-			break
-		}
-	}
-
-	if len(targets) > 0 {
-		// deep assignment start
-		deep := false
-		for _, targ := range targets_tok {
-			if Has(targ, Token{"SUB", ""}) {
-				nest := [][]Token{}
-				for Has(targ, Token{"SUB", ""}) {
-					for i := 0; i < len(targ); i++ {
-						if targ[i].Type == "SUB" {
-							nest = append(nest, targ[:i])
-							targ = Unlink(targ[i+1:])
-							break
-						}
-					}
-				}
-				nest = append(nest, Unlink(targ))
-				item := GetTargetAuto(tokens[0:1], &actions, sl)
-				ind_names := []Variable{Variable(nest[0][0].Value), Variable(item)}
-				for n, expr := range nest {
-					if n == 0 {
-						continue
-					}
-					// actlet := GetActs(expr, sl)
-					// targa := actlet[len(actlet)-1].Target
-					targa := GetTargetAuto(expr, &actions, sl)
-					ind_names = append(ind_names, Variable(targa))
-					// actions = append(actions, actlet...)
-				}
-				action := Action{"", "sub", ind_names, sl}
-				actions = append(actions, action)
-				deep = true
-			}
-			// TODO: fix this horrible piece of shit
-			if false && Has(targ, Token{"SUB", ""}) {
-				deep = true
-				/*
-					actlet := GetActs(targ, sl)
-					// t := TempName()
-					auto_target := tokens[0].Value // GetTargetAuto(tokens, &actions, sl)
-					if tokens[0].Type == "CONST" {
-						tname := TempName()
-						actions = append(actions, Action{tname, "const", []Variable{Variable(tokens[0].Value)}, sl})
-						auto_target = tname
-					}
-					t := TempName()
-					act := Action{t, "id", []Variable{Variable(auto_target)}, sl}
-					act2 := Action{TempName(), "id", []Variable{Variable(t)}, sl}
-				*/
-				actlet := GetActs(targ, sl)
-				// modify start
-				for n := range len(actlet) {
-					if actlet[n].Type == "'" {
-						actlet[n].Type = "''"
-					}
-				}
-				// modify end
-				auto_target := tokens[0].Value // GetTargetAuto(tokens, &actions, sl)
-				if tokens[0].Type == "CONST" {
-					tname := TempName()
-					actions = append(actions, Action{tname, "const", []Variable{Variable(tokens[0].Value)}, sl})
-					auto_target = tname
-				}
-				action := Action{"", "deep", []Variable{Variable(actlet[len(actlet)-1].Target), Variable(auto_target)}, sl}
-				actions = append(actions, actlet...)
-				actions = append(actions, action)
-			}
-		}
-		if deep {
-			return actions
-		}
-		// deep assignment end
-		if len(targets) == 1 {
-			if pointer {
-				if len(actions) > 0 {
-					actions = append(actions, Action{targets[0], "&=", []Variable{Variable(actions[len(actions)-1].Target)}, sl})
-				} else {
-					if tokens[0].Type == "WORD" {
-						actions = append(actions, Action{targets[0], "&=", []Variable{Variable(tokens[0].Value)}, sl})
-					} else {
-						tname := TempName()
-						actions = append(actions, Action{tname, "const", []Variable{Variable(tokens[0].Value)}, sl})
-						actions = append(actions, Action{targets[0], "&=", []Variable{Variable(tname)}, sl})
-					}
-				}
-			} else {
-				if len(actions) > 0 {
-					actions = append(actions, Action{targets[0], "=", []Variable{Variable(actions[len(actions)-1].Target)}, sl})
-				} else {
-					if tokens[0].Type == "WORD" {
-						actions = append(actions, Action{targets[0], "=", []Variable{Variable(tokens[0].Value)}, sl})
-					} else {
-						tname := TempName()
-						actions = append(actions, Action{tname, "const", []Variable{Variable(tokens[0].Value)}, sl})
-						actions = append(actions, Action{targets[0], "=", []Variable{Variable(tname)}, sl})
-					}
-				}
-			}
-		} else if len(targets) > 1 {
-			var iterable Variable
-			if len(actions) > 0 {
-				iterable = Variable(actions[len(actions)-1].Target)
-			} else {
-				if tokens[0].Type == "WORD" {
-					iterable = Variable(tokens[0].Value)
-				} else {
-					tname := TempName()
-					actions = append(actions, Action{tname, "const", []Variable{Variable(tokens[0].Value)}, sl})
-					iterable = Variable(tname)
-				}
-			}
-			for n := range len(targets) {
-				second := fmt.Sprintf("%d", n)
-				sname := TempName()
-				actions = append(actions, Action{sname, "const", []Variable{Variable(second)}, sl})
-				actions = append(actions, Action{targets[n], "'", []Variable{iterable, Variable(sname)}, sl})
-			}
-		}
-	}
-	return actions
+// firstColumn returns the column of the first character of line after its
+// indentation, where the caret of an error about the whole statement points.
+func firstColumn(line string) int {
+	return utf8.RuneCountInString(line) - utf8.RuneCountInString(strings.TrimLeft(line, " \t")) + 1
 }
 
-type CodePart struct {
-	Line        string
-	LineOG      string
-	Indentation int
-	N           int
-	TargetNode  string
-}
+// SyntaxErrors lists every syntax error of a source, in source order.
+type SyntaxErrors []SyntaxError
 
-func GetInd(line string) int {
-	for n := 0; n < len(line); n++ {
-		if line[n] != ' ' {
-			return n
-		}
+func (e SyntaxErrors) Error() string {
+	lines := []string{}
+	for _, err := range e {
+		lines = append(lines, err.Error())
 	}
-	return 0
+	return strings.Join(lines, "\n")
 }
 
-func fill_strings(source string, m map[string]string) string {
-	splitted := strings.Split(source, "\"")
-	lines := []string{splitted[0]}
-	for n := 1; n < len(splitted); n += 2 {
-		lines = append(lines, m[splitted[n]])
-		lines = append(lines, splitted[n+1])
+// Report renders the errors the way the interpreter reports them, pointing at
+// each one in its source line.
+func (e SyntaxErrors) Report(file string) string {
+	b := strings.Builder{}
+	for _, err := range e {
+		source := strings.TrimLeft(err.Source, " \t")
+		caret := max(err.Column-1-(len([]rune(err.Source))-len([]rune(source))), 0)
+		fmt.Fprintf(&b, "  File \"%s\", line %d, column %d\n    %s\n    %s^\n", file, err.Line, err.Column, source, strings.Repeat(" ", caret))
+		fmt.Fprintf(&b, "Syntax error: %s\n", err.Message)
 	}
-	return strings.Join(lines, "\"")
+	return b.String()
 }
 
-func trim_all(strs []string) []string {
-	for n := 0; n < len(strs); n++ {
-		strs[n] = strings.TrimSpace(strs[n])
+// GetCode compiles source, panicking with its SyntaxErrors when it does not
+// compile; Compile returns them instead.
+func GetCode(source string) Unit {
+	unit, err := Compile(source)
+	if err != nil {
+		panic(err)
 	}
-	return strs
+	return unit
 }
 
-var NodeN uint64
-
-func GetCode(source string) map[string][]Action {
+// Compile turns source into a unit. Malformed statements are reported as
+// SyntaxErrors, all of them and not only the first.
+func Compile(source string) (unit Unit, err error) {
 	source = strings.ReplaceAll(source, "\r\n", "\n")
+	errs := SyntaxErrors{}
+	at := 0 // line being compiled, for errors the statements cannot place
+	defer func() {
+		if r := recover(); r != nil {
+			errs = append(errs, SyntaxError{Line: at + 1, Column: firstColumn(strings.Split(source, "\n")[at]), Message: "invalid syntax"})
+		}
+		if len(errs) > 0 {
+			raw := strings.Split(source, "\n")
+			for n := range errs {
+				if errs[n].Line > 0 && errs[n].Line <= len(raw) {
+					errs[n].Source = raw[errs[n].Line-1]
+				}
+			}
+			sort.SliceStable(errs, func(i, j int) bool {
+				return errs[i].Line < errs[j].Line || errs[i].Line == errs[j].Line && errs[i].Column < errs[j].Column
+			})
+			unit, err = Unit{}, errs
+		}
+	}()
+	c := &Compiler{unit: unitN.Add(1), lambdas: make(map[string][]Action)}
 	compiled := make(map[string][]Action)
 	parts := []CodePart{}
-	source_no_strings, string_map := remove_strings(source)
-	// comment removal start
-	source_no_strings = remove_comment(source_no_strings)
-	// comment removal end
+	source_no_strings, literals, docs, literal_errs := remove_literals(source)
+	errs = append(errs, literal_errs...)
 	countables := make(map[string]int)
 	countables = map[string]int{"[": 0, "\"": 0, "(": 0, "]": 0, ")": 0, "{": 0, "}": 0}
 	buffer := []string{}
+	buffer_lines := []int{}
 	series := strings.Split(source_no_strings, "\n")
 	for ln, line := range series {
+		at = ln
 		if strings.TrimSpace(line) == "" { // experimental empty line remover
 			continue
 		}
 		for key := range countables {
 			countables[key] += strings.Count(line, key)
 		}
-		if countables["{"] > countables["}"] || countables["["] > countables["]"] || countables["("] > countables[")"] || countables["\""]%2 == 1 {
-			buffer = append(buffer, line)
-		} else {
-			buffer = append(buffer, line)
-			part := CodePart{Line: strings.Join(trim_all(Unlink(buffer)), "\n"), LineOG: fill_strings(strings.Join(trim_all(Unlink(buffer)), "\n"), string_map), Indentation: GetInd(strings.Join(buffer, "\n")), N: ln - (len(buffer) - 1)}
+		buffer = append(buffer, line)
+		buffer_lines = append(buffer_lines, ln)
+		if !(countables["{"] > countables["}"] || countables["["] > countables["]"] || countables["("] > countables[")"] || countables["\""]%2 == 1) {
+			part := CodePart{Line: strings.Join(trim_all(Unlink(buffer)), "\n"), LineOG: fill_strings(strings.Join(trim_all(Unlink(buffer)), "\n"), literals), Indentation: GetInd(strings.Join(buffer, "\n")), N: buffer_lines[0], Lines: buffer_lines, Doc: docOf(docs, buffer_lines[0])}
+			for _, l := range buffer {
+				part.Indents = append(part.Indents, len(l)-len(strings.TrimLeft(l, " \t")))
+			}
 			part.Line = RenderT(part.Line) // EXPERIMENTAL ###
-			// part := CodePart{strings.Join(trim_all(buffer), "\n"), fill_strings(strings.Join(trim_all(buffer), "\n"), string_map), GetInd(strings.Join(buffer, "\n")), ln - (len(buffer) - 1), ""} //Line{fill_strings(strings.Join(buffer, "\n"), string_map), strings.Join(trim_all(buffer), ""), ln}
 			parts = append(parts, part)
 			buffer = []string{}
+			buffer_lines = []int{}
+		}
+	}
+	if len(buffer) > 0 {
+		// the brackets opened by the last statement are never closed
+		part := CodePart{Line: strings.Join(trim_all(Unlink(buffer)), "\n"), Lines: buffer_lines}
+		for _, l := range buffer {
+			part.Indents = append(part.Indents, len(l)-len(strings.TrimLeft(l, " \t")))
+		}
+		_, line_errs := tokenizePart(part, literals)
+		if len(line_errs) == 0 {
+			line_errs = []SyntaxError{{Line: buffer_lines[0] + 1, Column: firstColumn(buffer[0]), Message: "statement is never closed"}}
 		}
+		errs = append(errs, line_errs...)
 	}
 	nodes := make(map[string][]CodePart)
 	maximal_ind := 0
@@ -1441,6 +564,7 @@ func GetCode(source string) map[string][]Action {
 		}
 	}
 	for maximal_ind > 0 {
+		nested := false
 		for start := 0; start < len(parts)-1; start++ {
 			if parts[start+1].Indentation > parts[start].Indentation && parts[start+1].Indentation == maximal_ind {
 				body := start + 1
@@ -1449,14 +573,23 @@ func GetCode(source string) map[string][]Action {
 					cp = append(cp, parts[body])
 					body++
 				}
-				nname := fmt.Sprintf("_node_%d", NodeN)
-				NodeN++
+				nname := c.NodeName()
 				nodes[nname] = cp
 				parts[start].TargetNode = nname
 				parts = Unlink(append(parts[:start+1], parts[body:]...))
+				nested = true
 				break
 			}
 		}
+		if !nested {
+			// only the first statement can be indented deepest with no
+			// statement above to nest in, the ones after it are taken as
+			// its siblings at the top level
+			errs = append(errs, SyntaxError{Line: parts[0].N + 1, Column: firstColumn(series[parts[0].N]), Message: "unexpected indent"})
+			for n, indentation := 0, parts[0].Indentation; n < len(parts) && parts[n].Indentation == indentation; n++ {
+				parts[n].Indentation = 0
+			}
+		}
 		maximal_ind = 0
 		for focus := 0; focus < len(parts); focus++ {
 			if parts[focus].Indentation > maximal_ind {
@@ -1464,39 +597,73 @@ func GetCode(source string) map[string][]Action {
 			}
 		}
 	}
-	nname := fmt.Sprintf("_node_%d", NodeN)
-	NodeN++
-	nodes[nname] = Unlink(parts)
+	entry := c.NodeName()
+	nodes[entry] = Unlink(parts)
 	for key := range nodes {
 		node_acts := []Action{}
 		for _, line := range nodes[key] {
-			toks := Tokenize(line.Line)
-			if line.TargetNode != "" {
-				toks = append(toks, Token{"LINK", line.TargetNode})
-			}
-			sl := SourceLine{line.LineOG, line.N}
-			if len(toks) > 0 && toks[0].Type == "DOLL" {
-				toks = toks[:1]
+			acts, line_errs := c.compilePart(line, literals)
+			if len(line_errs) > 0 {
+				errs = append(errs, line_errs...)
+				continue
 			}
-			acts := GetActs(toks, &sl)
 			node_acts = append(node_acts, acts...)
 			node_acts = append(node_acts, Action{Type: "GC"})
-			TempN = 0
 		}
-		for n, nact := range node_acts {
-			for m, v := range nact.Variables {
-				if strings.HasPrefix(string(v), "\"") && strings.HasSuffix(string(v), "\"") {
-					str, ok := string_map[string(v)[1:len(string(v))-1]]
-					if !ok {
-						continue
-					}
-					node_acts[n].Variables[m] = Variable("\"" + str + "\"")
+		fill_literals(node_acts, literals)
+		precompile(node_acts)
+		compiled[key] = node_acts
+	}
+	for key, acts := range c.lambdas {
+		fill_literals(acts, literals)
+		precompile(acts)
+		compiled[key] = acts
+	}
+	errs = append(errs, checkStructs(compiled)...)
+	resolveFrames(compiled, entry)
+	return Unit{Entry: entry, Code: compiled}, nil
+}
+
+// fill_literals gives the constants of the actions the values of the string
+// literals their placeholders stand for.
+func fill_literals(acts []Action, literals map[string]literal) {
+	for n, act := range acts {
+		for m, v := range act.Variables {
+			if strings.HasPrefix(string(v), "\"") && strings.HasSuffix(string(v), "\"") {
+				lit, ok := literals[string(v)[1:len(string(v))-1]]
+				if !ok {
+					continue
 				}
+				acts[n].Variables[m] = Variable("\"" + lit.value + "\"")
 			}
 		}
-		compiled[key] = node_acts
 	}
-	return compiled
+}
+
+// compilePart turns one statement into actions, or into the syntax errors
+// keeping it from compiling.
+func (c *Compiler) compilePart(line CodePart, literals map[string]literal) (acts []Action, errs []SyntaxError) {
+	defer func() {
+		c.tempN = 0
+		if r := recover(); r != nil {
+			acts, errs = nil, []SyntaxError{{Line: line.N + 1, Column: line.Indents[0] + 1, Message: "invalid syntax"}}
+		}
+	}()
+	toks, errs := tokenizePart(line, literals)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	stmt, errs := parseStatement(toks, line.TargetNode)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	sl := SourceLine{Source: line.LineOG, N: line.N, Col: line.Indentation + 1}
+	acts = c.lower(stmt, &sl)
+	if h, ok := stmt.(*HeaderStmt); ok && (h.Keyword.Value == "func" || h.Keyword.Value == "struct") && line.Doc != "" {
+		// the func and struct actions take their doc from the action before it
+		acts = append([]Action{{Target: h.Node, Type: "##", Variables: []Variable{Variable("\"" + line.Doc + "\"")}, Source: &sl}}, acts...)
+	}
+	return acts, nil
 }
 
 func GetLayout(signature string) *regexp.Regexp {
@@ -1516,7 +683,12 @@ type Function struct {
 	Name   string
 	Target string
 	Vars   []Variable
+	Params []Param // Vars with their defaults and rest marker, nil when all are required
 	Node   string
+	Doc    string  // the ## comment above its func statement
+	Env    any     // scope a closure was defined in, nil for other functions
+	Struct *Struct // the struct a constructor builds or a method belongs to
+	Recv   string  // variable a method was taken from, "" for other functions
 }
 
 // rpc START
@@ -1533,7 +705,7 @@ type CallReply struct {
 // rpc END
 
 func GenerateFuns() []Function {
-	strs := []string{"print", "out", "where", "len", "stats", "except", "sleep", "read", "write", "remove", "isdir", "mkdir", "abs", "lower", "upper", "map", "jsonp", "check_type", "exit", "type", "convert", "list", "span", "array", "pair", "append", "system", "keys", "source", "library", "run", "runf", "sort", "id", "ternary", "rand", "input", "glob", "env", "range", "fmt", "chdir", "split", "join", "cp", "mv", "rm", "pop", "itc", "cti", "has", "index", "replace", "re_match", "re_find", "rget", "rpost", "arrm", "value", "sub", "html_set_inner"}
+	strs := []string{"print", "out", "where", "len", "stats", "except", "sleep", "read", "write", "remove", "isdir", "mkdir", "abs", "lower", "upper", "map", "jsonp", "check_type", "exit", "type", "convert", "list", "span", "array", "pair", "append", "system", "keys", "source", "library", "run", "runf", "sort", "id", "ternary", "rand", "input", "glob", "env", "range", "fmt", "chdir", "split", "join", "cp", "mv", "rm", "pop", "itc", "cti", "has", "index", "replace", "re_match", "re_find", "rget", "rpost", "arrm", "value", "sub", "html_set_inner", "precision", "doc"}
 	fs := []Function{}
 	for _, str := range strs {
 		fs = append(fs, Function{Name: str})
@@ -1790,5 +962,6 @@ type Span struct {
 }
 
 type Pair struct {
-	Ids map[string]*MinPtr
+	Ids    map[string]*MinPtr
+	Struct *Struct // the type of an instance, nil for a plain pair
 }
@@ -0,0 +1,128 @@
+package bytecode
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// COMMENTS START
+
+// comment reads the comment starting at s[i], a "#" outside of any string.
+// A line comment ends before the line break, and one starting with "##" is a
+// doc comment whose text is returned. A block comment runs from "#[" to the
+// matching "]#", nests and may span lines; closed is false when it never
+// ends.
+func comment(s string, i int) (end int, doc string, is_doc, closed bool) {
+	if !strings.HasPrefix(s[i:], "#[") {
+		end = strings.IndexByte(s[i:], '\n')
+		if end < 0 {
+			end = len(s) - i
+		}
+		text := s[i : i+end]
+		if strings.HasPrefix(text, "##") {
+			doc, is_doc = strings.TrimPrefix(text[2:], " "), true
+		}
+		return i + end, doc, is_doc, true
+	}
+	depth := 0
+	for j := i; j < len(s); {
+		switch {
+		case strings.HasPrefix(s[j:], "#["):
+			depth++
+			j += 2
+		case strings.HasPrefix(s[j:], "]#"):
+			depth--
+			j += 2
+			if depth == 0 {
+				return j, "", false, true
+			}
+		default:
+			j++
+		}
+	}
+	return len(s), "", false, false
+}
+
+// docOf joins the doc comment lines right above the source line ln, from 0,
+// with nothing but indentation between them and the line.
+func docOf(docs map[int]string, ln int) string {
+	lines := []string{}
+	for n := ln - 1; ; n-- {
+		text, ok := docs[n]
+		if !ok {
+			break
+		}
+		lines = append([]string{text}, lines...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FuncDoc is a function defined by a unit along with its doc comment.
+type FuncDoc struct {
+	Name   string
+	Params []string // as declared, "port=80" or "...rest"
+	Line   int      // line of the func statement, from 1
+	Doc    string   // the ## lines above it, "" when it has none
+}
+
+// Funcs lists the functions a unit defines, at any depth, in source order.
+// A method is named after its struct, "Point.move".
+func (u Unit) Funcs() []FuncDoc {
+	structs := map[string]string{}
+	for _, acts := range u.Code {
+		for _, act := range acts {
+			if act.Type == "struct" {
+				structs[act.Target] = act.First() + "."
+			}
+		}
+	}
+	funcs := []FuncDoc{}
+	for node, acts := range u.Code {
+		for n, act := range acts {
+			if act.Type != "func" {
+				continue
+			}
+			f := FuncDoc{Name: structs[node] + act.First()}
+			for _, param := range ParseParams(act.Variables[1:]) {
+				f.Params = append(f.Params, param.Declared(u.Code))
+			}
+			if act.Source != nil {
+				f.Line = act.Source.N + 1
+			}
+			if n > 0 && acts[n-1].Type == "##" {
+				f.Doc = DocText(acts[n-1])
+			}
+			funcs = append(funcs, f)
+		}
+	}
+	sort.SliceStable(funcs, func(i, j int) bool { return funcs[i].Line < funcs[j].Line })
+	return funcs
+}
+
+// DocText is the doc comment carried by a ## action, which the compiler puts
+// right before the func action it documents.
+func DocText(act Action) string {
+	text := string(act.Variables[0])
+	return text[1 : len(text)-1]
+}
+
+// String formats the function as its func statement followed by its doc.
+func (f FuncDoc) String() string {
+	header := fmt.Sprintf("func %s", f.Name)
+	if len(f.Params) > 0 {
+		header += " " + strings.Join(f.Params, ", ")
+	}
+	if f.Doc == "" {
+		return header + ":\n"
+	}
+	lines := strings.Split(f.Doc, "\n")
+	for n := range lines {
+		if lines[n] != "" {
+			lines[n] = "    " + lines[n]
+		}
+	}
+	return header + ":\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// COMMENTS END
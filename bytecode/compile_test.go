@@ -0,0 +1,86 @@
+package bytecode
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// compileWithin compiles source, failing the test when it takes longer than
+// a second instead of hanging it.
+func compileWithin(t *testing.T, source string) error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		_, err := Compile(source)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(time.Second):
+		t.Fatalf("Compile(%q) did not return", source)
+	}
+	return nil
+}
+
+func TestCompileUnexpectedIndent(t *testing.T) {
+	for _, source := range []string{
+		" = 1",
+		"\n\n  !print 1",
+		"## doc\n    x",
+		"    x = 1\n    y = 2",
+		"  a = 1\nb = 2",
+	} {
+		err := compileWithin(t, source)
+		var errs SyntaxErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("Compile(%q) = %v, want syntax errors", source, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), "unexpected indent") {
+			t.Errorf("Compile(%q) = %v, want an unexpected indent", source, err)
+		}
+	}
+}
+
+func TestCompileAccepted(t *testing.T) {
+	for _, source := range []string{
+		"x = 1\nif x > 0:\n    if x > 1:\n        x = 2\n    x = 3\n!print x",
+		"d = $ echo hi\n!print d",
+	} {
+		if err := compileWithin(t, source); err != nil {
+			t.Errorf("Compile(%q) = %v", source, err)
+		}
+	}
+}
+
+func TestReportCaret(t *testing.T) {
+	for _, source := range []string{
+		"    x = 1",
+		"## doc\n  \tx",
+		"\n\n   !print x\ny = 2",
+		"x = (1 +",
+		"  x = (1 +",
+		"x = 1\nif x:\n    y = [1, 2\n",
+		"!print \"é\" + ",
+	} {
+		_, err := Compile(source)
+		var errs SyntaxErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("Compile(%q) = %v, want syntax errors", source, err)
+			continue
+		}
+		report := strings.Split(errs.Report("test.min"), "\n")
+		for n, e := range errs {
+			// every error takes a header, its source line, the caret and
+			// its message
+			shown, caret := []rune(report[4*n+1][4:]), strings.Index(report[4*n+2], "^")-4
+			line := []rune(e.Source)
+			if caret < 0 || caret >= len(shown) || e.Column < 1 || e.Column > len(line) || shown[caret] != line[e.Column-1] {
+				t.Errorf("Compile(%q): the caret of %q does not point at column %d:\n%s", source, e.Message, e.Column, strings.Join(report[4*n:4*n+3], "\n"))
+			}
+		}
+	}
+}
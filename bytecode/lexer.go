@@ -0,0 +1,256 @@
+package bytecode
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LEXER START
+
+type symbol struct {
+	text string
+	typ  string
+}
+
+// symbols are the operators of the language, longest first so the lexer
+// takes "<-" before "<".
+var symbols = func() []symbol {
+	s := []symbol{{"$", "DOLL"}, {",", "COMM"}, {".", "DOT"}, {"'", "SUB"}, {":", "COL"}, {"}", "C_CUR"}, {"{", "O_CUR"}, {"]", "C_BR"}, {"[", "O_BR"}, {")", "C_PAR"}, {"(", "O_PAR"}, {"!", "ACT"}, {"++", "PP"}, {"--", "MM"}, {"+", "PLUS"}, {"->", "R_ARR"}, {"-", "MINUS"}, {"*", "MUL"}, {"//", "DDIV"}, {"/", "DIV"}, {"^", "POW"}, {"%", "MOD"}, {"<", "LESS"}, {">", "GREAT"}, {"==", "ISEQ"}, {"!=", "NISEQ"}, {"<-", "L_ARR"}, {"&=", "PEQ"}, {"=", "EQ"}, {"...", "TDOT"}}
+	sort.SliceStable(s, func(i, j int) bool { return len(s[i].text) > len(s[j].text) })
+	return s
+}()
+
+// keywords are the operators written as words.
+var keywords = map[string]string{"or": "OR", "and": "AND", "not": "NOT"}
+
+var reg_const_token = regexp.MustCompile(`^(true|false|(-?[0-9]+\.[0-9]+)|(-?[0-9]+)|(b\.[0-9]+)|".*")$`)
+
+// lexer splits one statement into tokens in a single pass. The statement may
+// span several source lines, each trimmed of its indentation, and have its
+// strings replaced by placeholders; lines, indents and literals map the tokens
+// back to where they were written.
+type lexer struct {
+	source   string
+	pos      int                // byte offset of the next rune
+	row      int                // line of the statement being read
+	col      int                // column of the next rune, from 1
+	lines    []int              // source line of each statement line, from 0
+	indents  []int              // indentation of each statement line
+	shift    int                // lines taken by the literals read on this line
+	literals map[string]literal // literals of the placeholders by name
+	word     strings.Builder
+	wordAt   Token // position of the word being read
+	tokens   []Token
+	errs     []SyntaxError
+}
+
+func (l *lexer) line() int {
+	if l.row < len(l.lines) {
+		return l.lines[l.row] + 1 + l.shift
+	}
+	return l.row + 1 + l.shift
+}
+
+func (l *lexer) indent() int {
+	if l.row < len(l.indents) {
+		return l.indents[l.row]
+	}
+	return 0
+}
+
+func (l *lexer) here() Token {
+	return Token{Line: l.line(), Col: l.col}
+}
+
+// flush ends the word being read.
+func (l *lexer) flush() {
+	if l.word.Len() == 0 {
+		return
+	}
+	word := l.word.String()
+	l.word.Reset()
+	switch {
+	case keywords[word] != "":
+		l.tokens = append(l.tokens, l.wordAt.As(keywords[word], ""))
+	case reg_const_token.MatchString(word):
+		l.tokens = append(l.tokens, l.wordAt.As("CONST", word))
+	default:
+		l.tokens = append(l.tokens, l.wordAt.As("WORD", word))
+	}
+}
+
+// str reads a string literal, or the placeholder remove_literals left for one.
+func (l *lexer) str() {
+	start := l.here()
+	end, value, at, problem := readString(l.source, l.pos)
+	if problem != "" {
+		l.errs = append(l.errs, SyntaxError{Line: start.Line, Column: column(l.source[:at], start.Col, l.pos), Message: problem})
+		l.pos = len(l.source)
+		return
+	}
+	text := l.source[l.pos:end]
+	l.tokens = append(l.tokens, start.As("CONST", "\""+value+"\""))
+	l.pos = end
+	lit, ok := l.literals[value]
+	if !ok {
+		lit = literal{lines: strings.Count(text, "\n")}
+		lit.width = utf8.RuneCountInString(text[strings.LastIndexByte(text, '\n')+1:])
+	}
+	if lit.lines > 0 {
+		l.shift += lit.lines
+		l.col = lit.width + 1
+	} else {
+		l.col += lit.width
+	}
+}
+
+// comment skips a comment, which only statements tokenized on their own
+// still hold.
+func (l *lexer) comment() {
+	end, _, _, closed := comment(l.source, l.pos)
+	if !closed {
+		l.errs = append(l.errs, SyntaxError{Line: l.line(), Column: l.col, Message: "block comment is never closed"})
+	}
+	text := l.source[l.pos:end]
+	if nl := strings.LastIndexByte(text, '\n'); nl > -1 {
+		l.row += strings.Count(text, "\n")
+		l.col = l.indent() + utf8.RuneCountInString(text[nl+1:]) + 1
+	} else {
+		l.col += utf8.RuneCountInString(text)
+	}
+	l.pos = end
+}
+
+func (l *lexer) run() {
+	l.col = l.indent() + 1
+	for l.pos < len(l.source) {
+		r, size := utf8.DecodeRuneInString(l.source[l.pos:])
+		switch {
+		case r == '\n':
+			l.flush()
+			l.pos += size
+			l.row++
+			l.col = l.indent() + 1
+			if l.lines != nil {
+				// the next line of a compiled statement is placed by lines,
+				// which already skips the lines of its literals
+				l.shift = 0
+			}
+			continue
+		case unicode.IsSpace(r):
+			l.flush()
+		case r == '#':
+			l.flush()
+			l.comment()
+			continue
+		case l.word.Len() == 0 && starts_string(l.source, l.pos):
+			l.str()
+			continue
+		default:
+			if sym, ok := l.symbol(); ok {
+				l.flush()
+				l.tokens = append(l.tokens, l.here().As(sym.typ, ""))
+				l.pos += len(sym.text)
+				l.col += len(sym.text)
+				continue
+			}
+			if l.word.Len() == 0 {
+				l.wordAt = l.here()
+			}
+			l.word.WriteRune(r)
+		}
+		l.pos += size
+		l.col++
+	}
+	l.flush()
+}
+
+func (l *lexer) symbol() (symbol, bool) {
+	for _, sym := range symbols {
+		if strings.HasPrefix(l.source[l.pos:], sym.text) {
+			return sym, true
+		}
+	}
+	return symbol{}, false
+}
+
+// As returns a token of the type and value placed where t was written, used
+// for the tokens the compiler makes out of others.
+func (t Token) As(typ, value string) Token {
+	return Token{Type: typ, Value: value, Line: t.Line, Col: t.Col}
+}
+
+// Tokenize splits a single statement into tokens, positioned as if it started
+// on the first column of the first line.
+func Tokenize(sourcestr string) []Token {
+	l := &lexer{source: sourcestr}
+	l.run()
+	return lexed(l.tokens)
+}
+
+// tokenizePart splits a statement of a source into tokens positioned in the
+// source, along with the syntax errors found on the way.
+func tokenizePart(part CodePart, literals map[string]literal) ([]Token, []SyntaxError) {
+	l := &lexer{source: part.Line, lines: part.Lines, indents: part.Indents, literals: literals}
+	l.run()
+	if len(l.errs) > 0 {
+		return nil, l.errs
+	}
+	tokens := lexed(l.tokens)
+	return tokens, checkTokens(tokens)
+}
+
+func lexed(tokens []Token) []Token {
+	return DotConst(tokens)
+}
+
+var binaryOps = []string{"PLUS", "MINUS", "MUL", "DIV", "DDIV", "POW", "MOD", "LESS", "GREAT", "ISEQ", "NISEQ", "OR", "AND"}
+
+// opSymbols gives the operators by token type, as written and as the types
+// of the actions applying them.
+var opSymbols = map[string]string{"SUB": "'", "NOT": "not", "PLUS": "+", "MINUS": "-", "MUL": "*", "DIV": "/", "DDIV": "//", "POW": "^", "MOD": "%", "LESS": "<", "GREAT": ">", "ISEQ": "==", "NISEQ": "!=", "OR": "or", "AND": "and"}
+
+var closing = map[string]string{"O_PAR": "C_PAR", "O_BR": "C_BR", "O_CUR": "C_CUR"}
+var bracketText = map[string]string{"O_PAR": "(", "C_PAR": ")", "O_BR": "[", "C_BR": "]", "O_CUR": "{", "C_CUR": "}"}
+
+// checkTokens finds the brackets that are never closed, or closed by the
+// wrong bracket, before the parser takes them for a misplaced token. The shell
+// command following a $ is not checked.
+func checkTokens(tokens []Token) []SyntaxError {
+	if n := IndexTok(tokens, "DOLL"); n > -1 {
+		tokens = tokens[:n]
+	}
+	errs := []SyntaxError{}
+	fail := func(t Token, format string, args ...any) {
+		errs = append(errs, SyntaxError{Line: t.Line, Column: t.Col, Message: fmt.Sprintf(format, args...)})
+	}
+	open := []Token{}
+	for _, t := range tokens {
+		switch t.Type {
+		case "O_PAR", "O_BR", "O_CUR":
+			open = append(open, t)
+		case "C_PAR", "C_BR", "C_CUR":
+			if len(open) == 0 {
+				fail(t, "unexpected %q", bracketText[t.Type])
+				return errs
+			}
+			last := open[len(open)-1]
+			if closing[last.Type] != t.Type {
+				fail(t, "%q does not close %q of line %d, column %d", bracketText[t.Type], bracketText[last.Type], last.Line, last.Col)
+				return errs
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	if len(open) > 0 {
+		t := open[len(open)-1]
+		fail(t, "%q is never closed", bracketText[t.Type])
+	}
+	return errs
+}
+
+// LEXER END
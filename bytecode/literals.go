@@ -0,0 +1,237 @@
+package bytecode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LITERALS START
+
+// literal is a string literal of a source, which the compiler replaces by a
+// placeholder before splitting the source into statements.
+type literal struct {
+	text  string // as written, prefix and quotes included
+	value string // with its escapes resolved
+	lines int    // line breaks inside it
+	width int    // columns it takes on its last line, up to the closing quote
+}
+
+// readString reads the string literal starting at s[start], its r prefix or
+// its opening quote. It returns the index following the literal, its value
+// and, when it is malformed, the offset of the mistake and its description.
+//
+// "..." and """...""" resolve the escapes \n, \r, \t, \0, \\, \", \xHH and
+// \u{H...}, the code point written in hexadecimal; other backslashes are
+// kept as they are. r"..." and r"""...""" keep every backslash. Only the
+// triple quoted forms are meant to span lines.
+func readString(s string, start int) (end int, value string, at int, problem string) {
+	i := start
+	raw := s[i] == 'r'
+	if raw {
+		i++
+	}
+	quote := `"`
+	if strings.HasPrefix(s[i:], `"""`) {
+		quote = `"""`
+	}
+	i += len(quote)
+	b := strings.Builder{}
+	for i < len(s) {
+		switch {
+		case strings.HasPrefix(s[i:], quote):
+			return i + len(quote), b.String(), at, problem
+		case s[i] == '\n' && quote == `"`:
+			return i, "", start, "string is never closed"
+		case s[i] == '\\' && !raw && i+1 < len(s):
+			n, text, ok := escape(s[i:])
+			if !ok && problem == "" {
+				at, problem = i, "invalid escape sequence "+s[i:i+n]
+			}
+			b.WriteString(text)
+			i += n
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	return len(s), "", start, "string is never closed"
+}
+
+// escape resolves the escape sequence starting s, returning its length.
+// A malformed one ends before the first character that does not fit it.
+func escape(s string) (int, string, bool) {
+	simple := map[byte]string{'n': "\n", 'r': "\r", 't': "\t", '0': "\x00", '\\': "\\", '"': "\""}
+	if text, ok := simple[s[1]]; ok {
+		return 2, text, true
+	}
+	hex := func(from, most int) int {
+		n := 0
+		for from+n < len(s) && n < most && strings.IndexByte("0123456789abcdefABCDEF", s[from+n]) > -1 {
+			n++
+		}
+		return n
+	}
+	switch s[1] {
+	case 'x':
+		digits := hex(2, 2)
+		if digits < 2 {
+			return 2 + digits, "", false
+		}
+		code, _ := strconv.ParseUint(s[2:4], 16, 8)
+		return 4, string(rune(code)), true
+	case 'u':
+		if !strings.HasPrefix(s[2:], "{") {
+			return 2, "", false
+		}
+		digits := hex(3, 6)
+		if digits == 0 || !strings.HasPrefix(s[3+digits:], "}") {
+			return 3 + digits, "", false
+		}
+		code, _ := strconv.ParseUint(s[3:3+digits], 16, 32)
+		if !utf8.ValidRune(rune(code)) {
+			return 4 + digits, "", false
+		}
+		return 4 + digits, string(rune(code)), true
+	}
+	return 2, s[:2], true
+}
+
+// starts_string reports whether the string literal at s[i] begins there: a
+// quote, or an r right before one that does not end a name.
+func starts_string(s string, i int) bool {
+	if s[i] == '"' {
+		return true
+	}
+	if s[i] != 'r' || !strings.HasPrefix(s[i+1:], `"`) {
+		return false
+	}
+	prev, _ := utf8.DecodeLastRuneInString(s[:i])
+	return i == 0 || !(unicode.IsLetter(prev) || unicode.IsDigit(prev) || prev == '_')
+}
+
+// remove_literals replaces every string literal of source by a "_str_N"
+// placeholder and removes the comments, returning the text of the doc
+// comments by line, from 0. The text following a literal that spans lines is
+// moved up next to its placeholder and the line breaks are put back after it,
+// which keeps every line where it was; the lexer uses the lines and width of
+// the literal to place the tokens that follow it. A block comment is a blank
+// within a line, while the code following one that starts a line is indented
+// as the comment was.
+func remove_literals(source string) (string, map[string]literal, map[int]string, []SyntaxError) {
+	literals := make(map[string]literal)
+	docs := make(map[int]string)
+	errs := []SyntaxError{}
+	b := strings.Builder{}
+	line, col, pending := 1, 1, 0
+	line_start := 0
+	for i := 0; i < len(source); {
+		switch {
+		case source[i] == '\n':
+			b.WriteString(strings.Repeat("\n", pending+1))
+			pending = 0
+			line, col = line+1, 1
+			i++
+			line_start = i
+		case source[i] == '#':
+			end, doc, is_doc, closed := comment(source, i)
+			if !closed {
+				errs = append(errs, SyntaxError{Line: line, Column: col, Message: "block comment is never closed"})
+			}
+			indent := source[line_start:i]
+			leading := strings.TrimLeft(indent, " \t") == ""
+			if leading && strings.HasPrefix(source[i:], "#[") {
+				for end < len(source) && (source[end] == ' ' || source[end] == '\t') {
+					end++
+				}
+			}
+			text := source[i:end]
+			lines := strings.Count(text, "\n")
+			if is_doc && leading {
+				docs[line-1] = doc
+			}
+			switch {
+			case !strings.HasPrefix(text, "#["):
+			case leading && lines > 0 && pending == 0:
+				// the code after it is indented as the comment was
+				b.WriteString(strings.Repeat("\n", lines) + indent)
+			case leading:
+			case lines > 0:
+				// the code after it joins the line it starts on
+				pending += lines
+			default:
+				b.WriteString(strings.Repeat(" ", utf8.RuneCountInString(text)))
+			}
+			if nl := strings.LastIndexByte(text, '\n'); nl > -1 {
+				line += lines
+				col = utf8.RuneCountInString(text[nl+1:]) + 1
+				line_start = i + nl + 1
+			} else {
+				col += utf8.RuneCountInString(text)
+			}
+			i = end
+		case starts_string(source, i):
+			end, value, at, problem := readString(source, i)
+			text := source[i:end]
+			if problem != "" {
+				errs = append(errs, SyntaxError{Line: line + strings.Count(source[i:at], "\n"), Column: column(source[:at], col, i), Message: problem})
+			}
+			lit := literal{text: text, value: value, lines: strings.Count(text, "\n")}
+			last := text[strings.LastIndexByte(text, '\n')+1:]
+			lit.width = utf8.RuneCountInString(last)
+			name := fmt.Sprintf("_str_%d", len(literals))
+			literals[name] = lit
+			fmt.Fprintf(&b, "\"%s\"", name)
+			pending += lit.lines
+			line += lit.lines
+			if lit.lines > 0 {
+				col = lit.width + 1
+			} else {
+				col += lit.width
+			}
+			i = end
+		default:
+			_, size := utf8.DecodeRuneInString(source[i:])
+			b.WriteString(source[i : i+size])
+			col++
+			i += size
+		}
+	}
+	b.WriteString(strings.Repeat("\n", pending))
+	return b.String(), literals, docs, errs
+}
+
+// column gives the column of the end of before, whose character at offset
+// from is in column col.
+func column(before string, col, from int) int {
+	if nl := strings.LastIndexByte(before[from:], '\n'); nl > -1 {
+		return utf8.RuneCountInString(before[from+nl+1:]) + 1
+	}
+	return col + utf8.RuneCountInString(before[from:])
+}
+
+// fill_strings puts the literals back into a statement, as they were written.
+func fill_strings(source string, literals map[string]literal) string {
+	splitted := strings.Split(source, "\"")
+	b := strings.Builder{}
+	b.WriteString(splitted[0])
+	for n := 1; n < len(splitted); n += 2 {
+		lit, ok := literals[splitted[n]]
+		switch {
+		case ok:
+			b.WriteString(lit.text)
+		case n+1 < len(splitted):
+			b.WriteString("\"" + splitted[n] + "\"")
+		default:
+			b.WriteString("\"" + splitted[n])
+		}
+		if n+1 < len(splitted) {
+			b.WriteString(splitted[n+1])
+		}
+	}
+	return b.String()
+}
+
+// LITERALS END
@@ -0,0 +1,269 @@
+package bytecode
+
+import "fmt"
+
+// LOWER START
+
+// lowering turns the tree of a statement into actions, storing every
+// intermediate value in a temporary of the compiler.
+type lowering struct {
+	c    *Compiler
+	sl   *SourceLine
+	acts []Action
+}
+
+// lower returns the actions running s.
+func (c *Compiler) lower(s Stmt, sl *SourceLine) []Action {
+	l := &lowering{c: c, sl: sl}
+	l.stmt(s)
+	return l.acts
+}
+
+func (l *lowering) emit(target, typ string, vs ...Variable) string {
+	l.acts = append(l.acts, Action{Target: target, Type: typ, Variables: append([]Variable{}, vs...), Source: l.sl})
+	return target
+}
+
+// value lowers e and returns the variable holding its value. Names are used
+// as they are, without an action.
+func (l *lowering) value(e Expr) Variable {
+	switch e := e.(type) {
+	case *Ident:
+		return Variable(e.Name.Value)
+	case *ConstLit:
+		return Variable(l.emit(l.c.TempName(), "const", Variable(e.Value.Value)))
+	case *ParenExpr:
+		return l.value(e.X)
+	case *UnaryExpr:
+		if e.Op.Type == "NOT" {
+			x := l.value(e.X)
+			return Variable(l.emit(l.c.TempName(), "not", x))
+		}
+		zero := Variable(l.emit(l.c.TempName(), "const", "0"))
+		x := l.value(e.X)
+		return Variable(l.emit(l.c.TempName(), opSymbols[e.Op.Type], zero, x))
+	case *BinaryExpr:
+		x := l.value(e.X)
+		y := l.value(e.Y)
+		return Variable(l.emit(l.c.TempName(), opSymbols[e.Op.Type], x, y))
+	case *IndexExpr:
+		x := l.value(e.X)
+		i := l.value(e.Index)
+		return Variable(l.emit(l.c.TempName(), "'", x, i))
+	case *ListLit:
+		vs := l.values(e.Items)
+		target := l.c.TempName()
+		if e.Type.Value == "" {
+			return Variable(l.emit(target, "list", vs...))
+		}
+		typ := Variable(l.emit(l.c.TempName(), "const", Variable(fmt.Sprintf("b.%d", arrayTypes[e.Type.Value]))))
+		return Variable(l.emit(target, "array", append([]Variable{typ}, vs...)...))
+	case *PairLit:
+		vs := []Variable{}
+		for n := range e.Keys {
+			vs = append(vs, l.value(e.Keys[n]), l.value(e.Values[n]))
+		}
+		return Variable(l.emit(l.c.TempName(), "pair", vs...))
+	case *CallExpr:
+		fun := ""
+		if id, ok := e.Fun.(*Ident); ok {
+			fun = id.Name.Value
+		} else {
+			fun = string(l.value(e.Fun))
+		}
+		vs := []Variable{}
+		for n, arg := range e.Args {
+			if name := e.Names[n].Value; name != "" {
+				vs = append(vs, Variable(name+"="))
+			}
+			vs = append(vs, l.value(arg))
+		}
+		return Variable(l.emit(l.c.TempName(), fun, vs...))
+	case *FuncLit:
+		// the body becomes a node of its own, like the block of a func
+		node := l.c.NodeName()
+		body := &lowering{c: l.c, sl: l.sl}
+		body.emit(l.c.TempName(), "return", body.value(e.Body))
+		l.c.lambdas[node] = body.acts
+		vs := append([]Variable{Variable(node)}, l.params(e.Params)...)
+		return Variable(l.emit(l.c.TempName(), "<lambda>", vs...))
+	}
+	panic(fmt.Sprintf("cannot lower %T", e))
+}
+
+// params lowers the parameters of a function into the variables of its func
+// or <lambda> action, see ParseParams, or the fields of a struct into those
+// of its struct action. A default value becomes a node of its own, which the
+// call runs in the scope of the function when the parameter is left out.
+func (l *lowering) params(params []ParamDecl) []Variable {
+	vs := []Variable{}
+	for _, param := range params {
+		name := param.Name.Value
+		v := name
+		if param.Type.Value != "" {
+			v += "." + param.Type.Value
+		}
+		switch {
+		case param.Dots.Type != "":
+			vs = append(vs, Variable("..."+name))
+		case param.Default != nil:
+			node := l.c.NodeName()
+			def := &lowering{c: l.c, sl: l.sl}
+			def.emit(name, "=", def.value(param.Default))
+			l.c.lambdas[node] = def.acts
+			vs = append(vs, Variable(v+"="), Variable(node))
+		default:
+			vs = append(vs, Variable(v))
+		}
+	}
+	return vs
+}
+
+func (l *lowering) values(es []Expr) []Variable {
+	vs := []Variable{}
+	for _, e := range es {
+		vs = append(vs, l.value(e))
+	}
+	return vs
+}
+
+func (l *lowering) stmt(s Stmt) {
+	switch s := s.(type) {
+	case *ExprStmt:
+		x := s.X
+		for {
+			paren, ok := x.(*ParenExpr)
+			if !ok {
+				break
+			}
+			x = paren.X
+		}
+		switch x.(type) {
+		case *Ident, *ConstLit:
+		default:
+			l.value(x)
+		}
+	case *AssignStmt:
+		l.assign(s)
+	case *ShellStmt:
+		target := l.c.TempName()
+		if s.Target.Value == "" {
+			l.emit(target, "$")
+		} else {
+			l.emit(s.Target.Value, "$$")
+		}
+	case *ReturnStmt:
+		vs := l.values(s.Values)
+		l.emit(l.c.TempName(), "return", vs...)
+	case *IncDecStmt:
+		l.emit(s.Name.Value, ternary(s.Op.Type == "PP", "++", "--"), Variable(s.Name.Value))
+	case *ImportStmt:
+		l.emit(s.Name.Value, "<import>", l.value(s.Path))
+	case *PassStmt:
+	case *HeaderStmt:
+		l.header(s)
+	}
+}
+
+func (l *lowering) assign(s *AssignStmt) {
+	value := s.Value
+	if s.Modifier.Type != "" {
+		value = &BinaryExpr{Op: s.Modifier, X: s.Targets[0], Y: s.Value}
+	}
+	v := l.value(value)
+	if len(s.Targets) == 1 {
+		switch target := s.Targets[0].(type) {
+		case *Ident:
+			l.emit(target.Name.Value, ternary(s.Op.Type == "PEQ", "&=", "="), v)
+		case *IndexExpr:
+			l.store(target, v)
+		}
+		return
+	}
+	for n, target := range s.Targets {
+		i := Variable(l.emit(l.c.TempName(), "const", Variable(fmt.Sprint(n))))
+		switch target := target.(type) {
+		case *Ident:
+			l.emit(target.Name.Value, "'", v, i)
+		case *IndexExpr:
+			l.store(target, Variable(l.emit(l.c.TempName(), "'", v, i)))
+		}
+	}
+}
+
+// store lowers the assignment of v to an indexed target, `a'i'j = v`, into
+// a sub action naming a, v and the indexes.
+func (l *lowering) store(target *IndexExpr, v Variable) {
+	indexes := []Expr{}
+	var x Expr = target
+	for {
+		index, ok := x.(*IndexExpr)
+		if !ok {
+			break
+		}
+		indexes = append([]Expr{index.Index}, indexes...)
+		x = index.X
+	}
+	vs := []Variable{Variable(x.(*Ident).Name.Value), v}
+	vs = append(vs, l.values(indexes)...)
+	l.emit("", "sub", vs...)
+}
+
+func (l *lowering) header(h *HeaderStmt) {
+	kw := h.Keyword.Value
+	switch kw {
+	case "if", "repeat", "switch", "case":
+		var cond Variable
+		if h.Cond == nil {
+			cond = Variable(l.emit(l.c.TempName(), "const", "true"))
+		} else {
+			cond = l.value(h.Cond)
+		}
+		l.emit(h.Node, kw, cond)
+	case "while":
+		l.emit(h.Node, "while_start")
+		cond := l.value(h.Cond)
+		l.emit(h.Node, "while", cond)
+	case "else", "defer":
+		l.emit(h.Node, kw)
+	case "func", "struct":
+		vs := append([]Variable{Variable(h.Name.Value)}, l.params(h.Params)...)
+		l.emit(h.Node, kw, vs...)
+	case "for":
+		vs := []Variable{}
+		for _, flow := range h.Flows {
+			vs = append(vs, l.value(flow.From), Variable(flow.To.Value))
+		}
+		l.emit(h.Node, "for", vs...)
+	case "process":
+		vs := []Variable{"Nothing", "Nothing"}
+		if len(h.Flows) > 0 {
+			vs = []Variable{l.value(h.Flows[0].From), Variable(h.Flows[0].To.Value)}
+		}
+		for _, name := range h.Names {
+			vs = append(vs, Variable(name.Value))
+		}
+		l.emit(h.Node, "process", vs...)
+	case "pool":
+		vs := []Variable{}
+		for _, arrow := range []string{"R_ARR", "L_ARR"} {
+			for _, flow := range h.Flows {
+				if flow.Arrow.Type == arrow {
+					vs = append(vs, l.value(flow.From), Variable(flow.To.Value))
+				}
+			}
+			if arrow == "R_ARR" {
+				vs = append(vs, "Nothing")
+			}
+		}
+		l.emit(h.Node, "pool", vs...)
+	case "error":
+		vs := []Variable{}
+		for _, name := range h.Names {
+			vs = append(vs, Variable(name.Value))
+		}
+		l.emit(h.Node, "error", vs...)
+	}
+}
+
+// LOWER END
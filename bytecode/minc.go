@@ -0,0 +1,225 @@
+package bytecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MINC START
+
+// A .minc file is a compiled Unit: the magic bytes, the format version, the
+// version of the interpreter that wrote it, the entry node, the table of
+// source lines and then every node with its actions. Numbers are varints and
+// strings are length prefixed. Actions point into the source line table, so
+// actions of the same line share one SourceLine again once loaded.
+
+// Version is the version of the language and its interpreter.
+const Version = "4.3.7"
+
+// FormatVersion changes whenever the meaning of compiled actions does, files
+// of another format are rejected instead of being run wrongly.
+const FormatVersion = 1
+
+var mincMagic = []byte("MINC")
+
+// IsCompiled reports whether data holds a compiled unit rather than source.
+func IsCompiled(data []byte) bool {
+	return bytes.HasPrefix(data, mincMagic)
+}
+
+// Encode serializes the unit into the .minc format. Nodes are written sorted
+// by name so the same source always gives the same bytes.
+func (u Unit) Encode() []byte {
+	b := append([]byte{}, mincMagic...)
+	b = binary.AppendUvarint(b, FormatVersion)
+	b = appendString(b, Version)
+	b = appendString(b, u.Entry)
+	names := make([]string, 0, len(u.Code))
+	for name := range u.Code {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := []*SourceLine{}
+	line_ids := make(map[*SourceLine]uint64)
+	for _, name := range names {
+		for _, act := range u.Code[name] {
+			if _, ok := line_ids[act.Source]; act.Source != nil && !ok {
+				lines = append(lines, act.Source)
+				line_ids[act.Source] = uint64(len(lines))
+			}
+		}
+	}
+	b = binary.AppendUvarint(b, uint64(len(lines)))
+	for _, sl := range lines {
+		b = appendString(b, sl.Source)
+		b = binary.AppendVarint(b, int64(sl.N))
+		b = binary.AppendVarint(b, int64(sl.Col))
+	}
+	b = binary.AppendUvarint(b, uint64(len(names)))
+	for _, name := range names {
+		b = appendString(b, name)
+		b = binary.AppendUvarint(b, uint64(len(u.Code[name])))
+		for _, act := range u.Code[name] {
+			b = appendString(b, act.Target)
+			b = appendString(b, act.Type)
+			b = binary.AppendUvarint(b, uint64(len(act.Variables)))
+			for _, v := range act.Variables {
+				b = appendString(b, string(v))
+			}
+			b = binary.AppendUvarint(b, line_ids[act.Source]) // 0 for no source
+		}
+	}
+	return b
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// mincReader reads the .minc format, remembering the first error so the
+// decoder can check it once per section.
+type mincReader struct {
+	data []byte
+	err  error
+}
+
+var errTruncated = errors.New("compiled file is truncated or corrupt")
+
+func (r *mincReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	n, size := binary.Uvarint(r.data)
+	if size <= 0 {
+		r.err = errTruncated
+		return 0
+	}
+	r.data = r.data[size:]
+	return n
+}
+
+func (r *mincReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	n, size := binary.Varint(r.data)
+	if size <= 0 {
+		r.err = errTruncated
+		return 0
+	}
+	r.data = r.data[size:]
+	return n
+}
+
+func (r *mincReader) string() string {
+	n := r.uvarint()
+	if r.err != nil {
+		return ""
+	}
+	if n > uint64(len(r.data)) {
+		r.err = errTruncated
+		return ""
+	}
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s
+}
+
+// count reads the length of a section, bounded by the bytes left so that a
+// corrupt file cannot make the decoder allocate without limit.
+func (r *mincReader) count() int {
+	n := r.uvarint()
+	if n > uint64(len(r.data)) {
+		r.err = errTruncated
+		return 0
+	}
+	return int(n)
+}
+
+// Decode loads a unit written by Encode. Its nodes are renamed as if they had
+// just been compiled, so they never collide with the nodes of other units in
+// the same Code map.
+func Decode(data []byte) (Unit, error) {
+	if !IsCompiled(data) {
+		return Unit{}, errors.New("not a compiled Minimum file")
+	}
+	r := &mincReader{data: data[len(mincMagic):]}
+	format := r.uvarint()
+	version := r.string()
+	if r.err != nil {
+		return Unit{}, r.err
+	}
+	if format != FormatVersion {
+		return Unit{}, fmt.Errorf("compiled by Minimum %s (format %d), this is Minimum %s (format %d); compile the source again", version, format, Version, FormatVersion)
+	}
+	entry := r.string()
+	lines := make([]*SourceLine, r.count())
+	for n := range lines {
+		lines[n] = &SourceLine{Source: r.string(), N: int(r.varint()), Col: int(r.varint())}
+	}
+	code := make(map[string][]Action)
+	for nodes := r.count(); nodes > 0 && r.err == nil; nodes-- {
+		name := r.string()
+		acts := make([]Action, r.count())
+		for n := range acts {
+			acts[n].Target = r.string()
+			acts[n].Type = r.string()
+			acts[n].Variables = make([]Variable, r.count())
+			for m := range acts[n].Variables {
+				acts[n].Variables[m] = Variable(r.string())
+			}
+			line := r.uvarint()
+			if line > uint64(len(lines)) {
+				r.err = errTruncated
+			}
+			if line > 0 && r.err == nil {
+				acts[n].Source = lines[line-1]
+			}
+		}
+		code[name] = acts
+	}
+	if r.err != nil {
+		return Unit{}, r.err
+	}
+	if len(r.data) > 0 {
+		return Unit{}, errTruncated
+	}
+	if _, ok := code[entry]; !ok {
+		return Unit{}, errTruncated
+	}
+	return renameUnit(Unit{Entry: entry, Code: code}), nil
+}
+
+// renameUnit gives the nodes of the unit the prefix of a new compilation,
+// rewriting every action that refers to them.
+func renameUnit(u Unit) Unit {
+	unit := unitN.Add(1)
+	names := make(map[string]string, len(u.Code))
+	for name := range u.Code {
+		names[name] = fmt.Sprintf("_node_%d_%s", unit, name[strings.LastIndex(name, "_")+1:])
+	}
+	code := make(map[string][]Action, len(u.Code))
+	for name, acts := range u.Code {
+		for n := range acts {
+			if renamed, ok := names[acts[n].Target]; ok {
+				acts[n].Target = renamed
+			}
+			for m, v := range acts[n].Variables {
+				if renamed, ok := names[string(v)]; ok {
+					acts[n].Variables[m] = Variable(renamed)
+				}
+			}
+		}
+		precompile(acts)
+		code[names[name]] = acts
+	}
+	resolveFrames(code, names[u.Entry])
+	return Unit{Entry: names[u.Entry], Code: code}
+}
+
+// MINC END
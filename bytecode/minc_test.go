@@ -0,0 +1,76 @@
+package bytecode
+
+import (
+	"strings"
+	"testing"
+)
+
+const mincSource = "x = 1\nfunc f a:\n    if a > x:\n        return a\n    return x\n!print (!f 2), \"text\"\n"
+
+// suffix drops the unit prefix of a node name, which Decode renames.
+func suffix(name string) string {
+	if !strings.HasPrefix(name, "_node_") {
+		return name
+	}
+	return name[strings.LastIndex(name, "_")+1:]
+}
+
+func TestMincRoundTrip(t *testing.T) {
+	unit := GetCode(mincSource)
+	loaded, err := Decode(unit.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suffix(loaded.Entry) != suffix(unit.Entry) || loaded.Entry == unit.Entry {
+		t.Errorf("the entry %s came back as %s", unit.Entry, loaded.Entry)
+	}
+	if len(loaded.Code) != len(unit.Code) {
+		t.Fatalf("%d nodes came back as %d", len(unit.Code), len(loaded.Code))
+	}
+	nodes := map[string][]Action{}
+	for name, acts := range loaded.Code {
+		nodes[suffix(name)] = acts
+	}
+	for name, acts := range unit.Code {
+		got := nodes[suffix(name)]
+		if len(got) != len(acts) {
+			t.Fatalf("node %s has %d actions, want %d", name, len(got), len(acts))
+		}
+		for n, want := range acts {
+			act := got[n]
+			same := suffix(act.Target) == suffix(want.Target) && act.Type == want.Type && len(act.Variables) == len(want.Variables)
+			for m := range want.Variables {
+				same = same && suffix(string(act.Variables[m])) == suffix(string(want.Variables[m]))
+			}
+			if want.Source != nil {
+				same = same && act.Source != nil && *act.Source == *want.Source
+			}
+			if !same {
+				t.Errorf("action %d of node %s came back as %+v, want %+v", n, name, act, want)
+			}
+			if act.Frame == nil {
+				t.Errorf("action %d of node %s has no frame", n, name)
+			}
+		}
+	}
+}
+
+func TestMincRejected(t *testing.T) {
+	data := GetCode(mincSource).Encode()
+	if data[len(mincMagic)] != FormatVersion {
+		t.Fatal("the format version does not follow the magic bytes")
+	}
+	other := append([]byte{}, data...)
+	other[len(mincMagic)] = FormatVersion + 1
+	if _, err := Decode(other); err == nil || !strings.Contains(err.Error(), "format") {
+		t.Errorf("a file of another format gave %v", err)
+	}
+	for n := len(mincMagic); n < len(data); n++ {
+		if _, err := Decode(data[:n]); err == nil {
+			t.Fatalf("a file cut to %d of %d bytes was accepted", n, len(data))
+		}
+	}
+	if _, err := Decode([]byte("x = 1")); err == nil {
+		t.Error("source was decoded as a compiled file")
+	}
+}
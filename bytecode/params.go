@@ -0,0 +1,87 @@
+package bytecode
+
+import "strings"
+
+// PARAMETERS START
+
+// The variables of a func action, after the function name, and of a <lambda>
+// action, after its node, are the parameters as written: "name", "...name"
+// for the rest parameter or "name=" followed by the node that computes its
+// default value. A call lists its positional arguments first and then every
+// named one as "name=" followed by its value.
+
+// Param is a parameter of a user function.
+type Param struct {
+	Name    string
+	Default string // node assigning its default value to it, "" when it has none
+	Rest    bool   // collects the positional arguments left over into a list
+}
+
+// Keyword reports whether v is the "name=" marker of a named argument or a
+// parameter with a default, returning the name.
+func Keyword(v Variable) (string, bool) {
+	if s := string(v); strings.HasSuffix(s, "=") {
+		return s[:len(s)-1], true
+	}
+	return "", false
+}
+
+// ParseParams reads the parameters of a func or <lambda> action.
+func ParseParams(vs []Variable) []Param {
+	params := []Param{}
+	for n := 0; n < len(vs); n++ {
+		name := string(vs[n])
+		if kw, ok := Keyword(vs[n]); ok && n+1 < len(vs) {
+			params = append(params, Param{Name: kw, Default: string(vs[n+1])})
+			n++
+			continue
+		}
+		if rest, ok := strings.CutPrefix(name, "..."); ok {
+			params = append(params, Param{Name: rest, Rest: true})
+			continue
+		}
+		params = append(params, Param{Name: name})
+	}
+	return params
+}
+
+// SplitArgs separates the arguments of a call into the positional ones and
+// the named ones, in the order they were written.
+func SplitArgs(vs []Variable) (positional []Variable, names []string, named []Variable) {
+	for n := 0; n < len(vs); n++ {
+		if kw, ok := Keyword(vs[n]); ok && n+1 < len(vs) {
+			names, named = append(names, kw), append(named, vs[n+1])
+			n++
+			continue
+		}
+		positional = append(positional, vs[n])
+	}
+	return positional, names, named
+}
+
+// ParamNames are the names of the parameters, in order.
+func ParamNames(params []Param) []Variable {
+	names := []Variable{}
+	for _, p := range params {
+		names = append(names, Variable(p.Name))
+	}
+	return names
+}
+
+// Declared writes the parameter back as it is declared, its default value
+// shown when the node in code assigns a constant and as "..." otherwise.
+func (p Param) Declared(code map[string][]Action) string {
+	switch {
+	case p.Rest:
+		return "..." + p.Name
+	case p.Default == "":
+		return p.Name
+	}
+	acts := code[p.Default]
+	if len(acts) == 2 && acts[0].Type == "const" && acts[1].Type == "=" {
+		return p.Name + "=" + acts[0].First()
+	}
+	return p.Name + "=..."
+}
+
+// PARAMETERS END
@@ -0,0 +1,601 @@
+package bytecode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PARSER START
+
+// parser reads one statement by recursive descent, following the grammar of
+// ast.go. Mistakes panic with a SyntaxError that parseStatement recovers.
+type parser struct {
+	tokens []Token
+	n      int    // index of the next token
+	node   string // node of the statement's block
+	loose  bool   // headers may leave out their block
+	items  bool   // commas separate items, so calls take a single argument
+}
+
+// headers are the keywords of the statements opening a block.
+var headers = map[string]bool{"if": true, "while": true, "repeat": true, "switch": true, "case": true, "else": true, "defer": true, "func": true, "struct": true, "for": true, "process": true, "pool": true, "error": true}
+
+// arrayTypes gives the element type of an array literal by its name.
+var arrayTypes = map[string]byte{"noth": NOTH, "int": INT, "float": FLOAT, "str": STR, "arr": ARR, "list": LIST, "pair": PAIR, "bool": BOOL, "byte": BYTE, "func": FUNC, "id": ID}
+
+var binaryLevels = [][]string{{"OR", "AND"}, {"ISEQ", "NISEQ", "LESS", "GREAT"}, {"PLUS", "MINUS"}, {"MUL", "DIV", "DDIV", "MOD"}}
+var unaryOps = []string{"MINUS", "PLUS", "NOT"}
+var modifiers = []string{"PLUS", "MINUS", "MUL", "DIV", "DDIV", "MOD", "POW"}
+
+// parseStatement parses the tokens of one statement, node being the node of
+// the indented block following it, if any.
+func parseStatement(tokens []Token, node string) (s Stmt, errs []SyntaxError) {
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			s, errs = nil, []SyntaxError{err}
+		}
+	}()
+	p := &parser{tokens: tokens, node: node}
+	s = p.statement()
+	if _, ok := s.(*HeaderStmt); node != "" && !ok {
+		p.fail(s.Pos(), "only a statement ending with \":\" can be followed by an indented block")
+	}
+	return s, nil
+}
+
+// ParseStatement parses a single statement into its tree, positioned as
+// Tokenize does. A header such as `if x:` parses without its block.
+func ParseStatement(source string) (Stmt, error) {
+	tokens := Tokenize(source)
+	if errs := checkTokens(tokens); len(errs) > 0 {
+		return nil, SyntaxErrors(errs)
+	}
+	s, errs := func() (s Stmt, errs []SyntaxError) {
+		defer func() {
+			if r := recover(); r != nil {
+				err, ok := r.(SyntaxError)
+				if !ok {
+					panic(r)
+				}
+				errs = []SyntaxError{err}
+			}
+		}()
+		p := &parser{tokens: tokens, loose: true}
+		return p.statement(), nil
+	}()
+	if len(errs) > 0 {
+		return nil, SyntaxErrors(errs)
+	}
+	return s, nil
+}
+
+func (p *parser) fail(t Token, format string, args ...any) {
+	panic(SyntaxError{Line: t.Line, Column: t.Col, Message: fmt.Sprintf(format, args...)})
+}
+
+func (p *parser) peek() Token {
+	if p.n < len(p.tokens) {
+		return p.tokens[p.n]
+	}
+	end := Token{Type: "EOF"}
+	if len(p.tokens) > 0 {
+		end = p.tokens[len(p.tokens)-1].As("EOF", "")
+	}
+	return end
+}
+
+func (p *parser) next() Token {
+	t := p.peek()
+	if p.n < len(p.tokens) {
+		p.n++
+	}
+	return t
+}
+
+func (p *parser) atEnd() bool {
+	return p.n >= len(p.tokens)
+}
+
+func (p *parser) expect(typ string) Token {
+	if t := p.peek(); t.Type != typ {
+		p.fail(t, "expected %s, found %s", describe(Token{Type: typ}), describe(t))
+	}
+	return p.next()
+}
+
+// done fails on the tokens left after a whole statement or part of one.
+func (p *parser) done() {
+	if !p.atEnd() {
+		p.fail(p.peek(), "unexpected %s", describe(p.peek()))
+	}
+}
+
+// sub parses tokens, a part of the statement, with parse up to their end.
+func (p *parser) sub(tokens []Token, parse func(*parser) Expr) Expr {
+	q := &parser{tokens: tokens}
+	x := parse(q)
+	q.done()
+	return x
+}
+
+// describe names a token for error messages.
+func describe(t Token) string {
+	switch {
+	case t.Type == "EOF":
+		return "end of statement"
+	case t.Type == "WORD" && t.Value == "":
+		return "a name"
+	case t.Type == "CONST" && strings.HasPrefix(t.Value, "\""):
+		return "string"
+	case t.Type == "WORD" || t.Type == "CONST":
+		return fmt.Sprintf("%q", t.Value)
+	case opSymbols[t.Type] != "":
+		return fmt.Sprintf("%q", opSymbols[t.Type])
+	}
+	for _, sym := range symbols {
+		if sym.typ == t.Type {
+			return fmt.Sprintf("%q", sym.text)
+		}
+	}
+	return t.Type
+}
+
+// startsOperand reports whether an expression can begin with t.
+func startsOperand(t Token) bool {
+	return Has([]string{"WORD", "CONST", "O_PAR", "O_BR", "O_CUR", "ACT"}, t.Type) || Has(unaryOps, t.Type)
+}
+
+// operand fails when the operator op is not followed by an expression.
+func (p *parser) operand(op Token, which string) {
+	if !startsOperand(p.peek()) {
+		p.fail(op, "%q is missing its %s", opSymbols[op.Type], which)
+	}
+}
+
+func (p *parser) statement() Stmt {
+	if len(p.tokens) == 0 {
+		return &PassStmt{}
+	}
+	first, last := p.tokens[0], p.tokens[len(p.tokens)-1]
+	switch {
+	case HasTok(p.tokens, "DOLL"):
+		dollar := IndexTok(p.tokens, "DOLL")
+		s := &ShellStmt{Dollar: p.tokens[dollar]}
+		if before := p.tokens[:dollar]; len(before) > 0 {
+			if len(before) != 2 || before[0].Type != "WORD" || (before[1].Type != "EQ" && before[1].Type != "PEQ") {
+				p.fail(before[0], "a command can only be assigned to a single name")
+			}
+			s.Target = before[0]
+		}
+		return s
+	case first.Type == "WORD" && headers[first.Value] && (last.Type == "COL" || p.node != ""):
+		h := p.header()
+		p.done()
+		return h
+	case first.Type == "WORD" && first.Value == "return":
+		p.next()
+		s := &ReturnStmt{Keyword: first}
+		p.items = true
+		for !p.atEnd() {
+			if len(s.Values) > 0 {
+				p.expect("COMM")
+			}
+			s.Values = append(s.Values, p.expr())
+		}
+		return s
+	case first.Type == "WORD" && first.Value == "import" && len(p.tokens) > 1 && p.tokens[1].Type == "CONST":
+		s := &ImportStmt{Keyword: p.next(), Path: &ConstLit{Value: p.next()}}
+		if !strings.HasPrefix(s.Path.Value.Value, "\"") {
+			p.fail(s.Path.Value, "\"import\" must be followed by the path of the module, as a string")
+		}
+		if as := p.next(); as.Type != "WORD" || as.Value != "as" {
+			p.fail(as, "expected \"as\" and a name after the path of the module, found %s", describe(as))
+		}
+		s.Name = p.expect("WORD")
+		p.done()
+		return s
+	case len(p.tokens) == 1 && first.Type == "TDOT":
+		return &PassStmt{Dots: first}
+	case len(p.tokens) == 2 && first.Type == "WORD" && (last.Type == "PP" || last.Type == "MM"):
+		return &IncDecStmt{Name: first, Op: last}
+	}
+	if eq := p.assignment(); eq > -1 {
+		return p.assign(eq)
+	}
+	s := &ExprStmt{X: p.expr()}
+	p.done()
+	return s
+}
+
+// assignment returns the index of the "=" or "&=" outside of brackets, -1
+// when the statement assigns nothing. One after a call outside of brackets
+// passes an argument by name.
+func (p *parser) assignment() int {
+	level := 0
+	for n, t := range p.tokens {
+		switch t.Type {
+		case "O_PAR", "O_BR", "O_CUR":
+			level++
+		case "C_PAR", "C_BR", "C_CUR":
+			level--
+		case "ACT":
+			if level == 0 {
+				return -1
+			}
+		case "EQ", "PEQ":
+			if level == 0 {
+				return n
+			}
+		}
+	}
+	return -1
+}
+
+func (p *parser) assign(eq int) Stmt {
+	s := &AssignStmt{Op: p.tokens[eq]}
+	symbol := map[string]string{"EQ": "=", "PEQ": "&="}[s.Op.Type]
+	end := eq
+	if eq > 1 && Has(modifiers, p.tokens[eq-1].Type) {
+		s.Modifier = p.tokens[eq-1]
+		symbol = opSymbols[s.Modifier.Type] + symbol
+		end--
+	}
+	if end == 0 {
+		p.fail(p.tokens[end], "%q has nothing to assign to", symbol)
+	}
+	if eq == len(p.tokens)-1 {
+		p.fail(s.Op, "%q has no value to assign", symbol)
+	}
+	targets := &parser{tokens: p.tokens[:end]}
+	for {
+		t := targets.postfix()
+		if !assignable(t) {
+			targets.fail(t.Pos(), "cannot assign to this expression")
+		}
+		s.Targets = append(s.Targets, t)
+		if targets.atEnd() {
+			break
+		}
+		targets.expect("COMM")
+	}
+	_, single := s.Targets[0].(*Ident)
+	switch {
+	case s.Op.Type == "PEQ" && (len(s.Targets) > 1 || !single):
+		p.fail(s.Op, "%q needs a single variable name on its left", symbol)
+	case s.Modifier.Type != "" && len(s.Targets) > 1:
+		p.fail(s.Modifier, "%q cannot assign to several targets", symbol)
+	}
+	s.Value = p.sub(p.tokens[eq+1:], (*parser).expr)
+	return s
+}
+
+// assignable reports whether e is a name, or a name indexed with ' and .
+func assignable(e Expr) bool {
+	switch e := e.(type) {
+	case *Ident:
+		return true
+	case *IndexExpr:
+		return assignable(e.X)
+	}
+	return false
+}
+
+func (p *parser) header() *HeaderStmt {
+	kw := p.next()
+	h := &HeaderStmt{Keyword: kw, Node: p.node}
+	if p.node == "" && !p.loose {
+		p.fail(kw, "%q must be followed by an indented block", kw.Value)
+	}
+	if last := p.tokens[len(p.tokens)-1]; last.Type != "COL" {
+		p.fail(last, "expected \":\" at the end of the %q statement", kw.Value)
+	}
+	p.tokens = p.tokens[:len(p.tokens)-1]
+	switch kw.Value {
+	case "if", "while", "repeat":
+		if p.atEnd() {
+			p.fail(kw, "%q is missing its condition", kw.Value)
+		}
+		h.Cond = p.expr()
+	case "switch", "case":
+		if !p.atEnd() {
+			h.Cond = p.expr()
+		}
+	case "else", "defer":
+	case "func":
+		if p.peek().Type != "WORD" {
+			p.fail(kw, "\"func\" must be followed by the name of the function")
+		}
+		h.Name = p.next()
+		h.Params = p.params(func() bool { return p.atEnd() }, false)
+	case "struct":
+		if p.peek().Type != "WORD" {
+			p.fail(kw, "\"struct\" must be followed by the name of the type")
+		}
+		h.Name = p.next()
+		h.Params = p.params(func() bool { return p.atEnd() }, true)
+	case "for":
+		for len(h.Flows) == 0 || !p.atEnd() {
+			if len(h.Flows) > 0 {
+				p.expect("COMM")
+			}
+			from := p.expr()
+			arrow := p.expect("R_ARR")
+			h.Flows = append(h.Flows, Flow{From: from, Arrow: arrow, To: p.expect("WORD")})
+		}
+	case "process":
+		for !p.atEnd() {
+			if len(h.Names)+len(h.Flows) > 0 {
+				p.expect("COMM")
+			}
+			name := p.expect("WORD")
+			if p.peek().Type != "R_ARR" {
+				h.Names = append(h.Names, name)
+				continue
+			}
+			if len(h.Flows) > 0 {
+				p.fail(p.peek(), "\"process\" takes a single \"->\"")
+			}
+			arrow := p.next()
+			h.Flows = append(h.Flows, Flow{From: &Ident{Name: name}, Arrow: arrow, To: p.expect("WORD")})
+		}
+	case "pool":
+		for len(h.Flows) == 0 || !p.atEnd() {
+			if len(h.Flows) > 0 {
+				p.expect("COMM")
+			}
+			from := p.expect("WORD")
+			arrow := p.next()
+			if arrow.Type != "R_ARR" && arrow.Type != "L_ARR" {
+				p.fail(arrow, "expected \"->\" or \"<-\", found %s", describe(arrow))
+			}
+			h.Flows = append(h.Flows, Flow{From: &Ident{Name: from}, Arrow: arrow, To: p.expect("WORD")})
+		}
+	case "error":
+		for !p.atEnd() {
+			if len(h.Names) > 0 {
+				p.expect("COMM")
+			}
+			t := p.next()
+			if t.Type != "WORD" && !(t.Type == "CONST" && strings.HasPrefix(t.Value, "\"")) {
+				p.fail(t, "expected a name or an error type, found %s", describe(t))
+			}
+			h.Names = append(h.Names, t)
+		}
+	}
+	return h
+}
+
+func (p *parser) expr() Expr {
+	return p.binary(0)
+}
+
+func (p *parser) binary(level int) Expr {
+	if level == len(binaryLevels) {
+		return p.unary()
+	}
+	x := p.binary(level + 1)
+	for Has(binaryLevels[level], p.peek().Type) {
+		op := p.next()
+		p.operand(op, "right operand")
+		x = &BinaryExpr{Op: op, X: x, Y: p.binary(level + 1)}
+	}
+	return x
+}
+
+func (p *parser) unary() Expr {
+	if Has(unaryOps, p.peek().Type) {
+		op := p.next()
+		p.operand(op, "operand")
+		return &UnaryExpr{Op: op, X: p.unary()}
+	}
+	return p.power()
+}
+
+func (p *parser) power() Expr {
+	x := p.postfix()
+	for p.peek().Type == "POW" {
+		op := p.next()
+		p.operand(op, "right operand")
+		x = &BinaryExpr{Op: op, X: x, Y: p.signed((*parser).postfix)}
+	}
+	return x
+}
+
+// signed parses an operand of ^ or ', which may carry its own sign.
+func (p *parser) signed(operand func(*parser) Expr) Expr {
+	if Has(unaryOps, p.peek().Type) {
+		op := p.next()
+		p.operand(op, "operand")
+		return &UnaryExpr{Op: op, X: p.signed(operand)}
+	}
+	return operand(p)
+}
+
+func (p *parser) postfix() Expr {
+	x := p.primary()
+	for {
+		switch t := p.peek(); t.Type {
+		case "SUB":
+			p.next()
+			p.operand(t, "index")
+			x = &IndexExpr{Op: t, X: x, Index: p.signed((*parser).primary)}
+		case "DOT":
+			p.next()
+			switch field := p.peek(); {
+			case field.Type == "O_BR":
+				id, ok := x.(*Ident)
+				if _, known := arrayTypes[id.Name.Value]; !ok || !known {
+					p.fail(x.Pos(), "an array literal must start with a type name, such as int.[...]")
+				}
+				x = p.list(id.Name)
+			case field.Type == "WORD" || field.Type == "CONST" && !strings.ContainsAny(field.Value, "\".-"):
+				p.next()
+				x = &IndexExpr{Op: t, X: x, Index: &ConstLit{Value: field.As("CONST", "\""+field.Value+"\"")}}
+			default:
+				p.fail(t, "\".\" must be followed by a field name")
+			}
+		default:
+			return x
+		}
+	}
+}
+
+func (p *parser) primary() Expr {
+	switch t := p.peek(); t.Type {
+	case "WORD":
+		if t.Value == "func" && (p.n+1 >= len(p.tokens) || p.tokens[p.n+1].Type != "DOT") {
+			return p.lambda()
+		}
+		return &Ident{Name: p.next()}
+	case "CONST":
+		return &ConstLit{Value: p.next()}
+	case "O_PAR":
+		p.next()
+		items := p.items
+		p.items = false
+		x := &ParenExpr{Open: t, X: p.expr()}
+		p.items = items
+		p.expect("C_PAR")
+		return x
+	case "O_BR":
+		return p.list(Token{})
+	case "O_CUR":
+		return p.pair()
+	case "ACT":
+		return p.call()
+	default:
+		if Has(binaryOps, t.Type) {
+			p.fail(t, "%q is missing its left operand", opSymbols[t.Type])
+		}
+		p.fail(t, "unexpected %s", describe(t))
+		return nil
+	}
+}
+
+func (p *parser) list(typ Token) Expr {
+	l := &ListLit{Type: typ, Open: p.expect("O_BR")}
+	items := p.items
+	p.items = true
+	for p.peek().Type != "C_BR" {
+		l.Items = append(l.Items, p.expr())
+		if p.peek().Type != "C_BR" {
+			p.expect("COMM")
+		}
+	}
+	p.next()
+	p.items = items
+	return l
+}
+
+func (p *parser) pair() Expr {
+	pr := &PairLit{Open: p.expect("O_CUR")}
+	items := p.items
+	p.items = true
+	for p.peek().Type != "C_CUR" {
+		pr.Keys = append(pr.Keys, p.expr())
+		p.expect("COL")
+		pr.Values = append(pr.Values, p.expr())
+		if p.peek().Type != "C_CUR" {
+			p.expect("COMM")
+		}
+	}
+	p.next()
+	p.items = items
+	return pr
+}
+
+func (p *parser) call() Expr {
+	bang := p.next()
+	if t := p.peek(); !Has([]string{"WORD", "O_PAR", "ACT"}, t.Type) {
+		p.fail(bang, "\"!\" must be followed by the function to call")
+	}
+	c := &CallExpr{Bang: bang, Fun: p.postfix()}
+	if !startsOperand(p.peek()) {
+		return c
+	}
+	p.arg(c)
+	for !p.items && p.peek().Type == "COMM" {
+		p.next()
+		p.arg(c)
+	}
+	return c
+}
+
+// arg reads an argument of the call c, `name=expr` passing it by name.
+func (p *parser) arg(c *CallExpr) {
+	name := Token{}
+	if p.peek().Type == "WORD" && p.n+1 < len(p.tokens) && p.tokens[p.n+1].Type == "EQ" {
+		name = p.next()
+		if eq := p.next(); !startsOperand(p.peek()) {
+			p.fail(eq, "argument %q is missing its value", name.Value)
+		}
+	} else if n := len(c.Names); n > 0 && c.Names[n-1].Value != "" {
+		p.fail(p.peek(), "a positional argument cannot follow a named one")
+	}
+	c.Names = append(c.Names, name)
+	c.Args = append(c.Args, p.expr())
+}
+
+func (p *parser) lambda() Expr {
+	f := &FuncLit{Func: p.next()}
+	f.Params = p.params(func() bool { return p.peek().Type == "COL" }, false)
+	p.next()
+	items := p.items
+	p.items = true
+	f.Body = p.expr()
+	p.items = items
+	return f
+}
+
+// params reads the parameters of a func statement or a lambda up to end, or
+// with typed set the fields of a struct, which may name their type and have
+// no rest parameter. A default value ends at the next comma, the rest
+// parameter comes last and every parameter following one with a default has
+// one too.
+func (p *parser) params(end func() bool, typed bool) []ParamDecl {
+	params := []ParamDecl{}
+	names := map[string]bool{}
+	for !end() {
+		if len(params) > 0 {
+			p.expect("COMM")
+			if last := params[len(params)-1]; last.Dots.Type != "" {
+				p.fail(last.Dots, "the rest parameter %q must be the last one", last.Name.Value)
+			}
+		}
+		param := ParamDecl{}
+		if p.peek().Type == "TDOT" && !typed {
+			param.Dots = p.next()
+		}
+		if t := p.peek(); t.Type != "WORD" {
+			p.fail(t, "expected a parameter name, found %s", describe(t))
+		}
+		param.Name = p.next()
+		if names[param.Name.Value] {
+			p.fail(param.Name, "parameter %q is declared twice", param.Name.Value)
+		}
+		names[param.Name.Value] = true
+		if typed && p.peek().Type == "WORD" {
+			param.Type = p.next()
+		}
+		if eq := p.peek(); eq.Type == "EQ" && param.Dots.Type == "" {
+			p.next()
+			if !startsOperand(p.peek()) {
+				p.fail(eq, "parameter %q is missing its default value", param.Name.Value)
+			}
+			items := p.items
+			p.items = true
+			param.Default = p.expr()
+			p.items = items
+		} else if n := len(params); n > 0 && params[n-1].Default != nil && param.Dots.Type == "" {
+			p.fail(param.Name, "parameter %q needs a default value, as the ones before it have", param.Name.Value)
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+// PARSER END
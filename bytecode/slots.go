@@ -0,0 +1,86 @@
+package bytecode
+
+// SLOTS START
+
+// NoSymbol marks the target or variable of an action that is not a variable
+// of its frame: a node, a keyword of a named argument or no name at all.
+const NoSymbol = -1
+
+// Frame is the layout of the variables of a scope: the top level of a unit, a
+// function or a lambda, together with the blocks they run in that scope. Each
+// name its actions use gets a symbol, the index of the name in Names, which
+// the interpreter looks the slot of the variable up by in a scope that follows
+// the layout.
+type Frame struct {
+	Names []string
+	Index map[string]int32 // the symbol of each name
+}
+
+func (f *Frame) symbol(name string) int32 {
+	if sym, ok := f.Index[name]; ok {
+		return sym
+	}
+	sym := int32(len(f.Names))
+	f.Names = append(f.Names, name)
+	f.Index[name] = sym
+	return sym
+}
+
+// resolveFrames is the pass numbering the variables of the nodes of code that
+// the entry node reaches, each func statement and lambda starting a frame of
+// its own with the nodes of its body and of its default values. An action
+// gets its Frame, and in Syms the symbol of its target followed by those of
+// its variables.
+func resolveFrames(code map[string][]Action, entry string) {
+	framed := make(map[string]bool, len(code))
+	sym := func(f *Frame, name string) int32 {
+		if _, node := code[name]; node || name == "" {
+			return NoSymbol
+		}
+		if _, kw := Keyword(Variable(name)); kw {
+			return NoSymbol
+		}
+		return f.symbol(name)
+	}
+	var walk func(node string, f *Frame)
+	walk = func(node string, f *Frame) {
+		if framed[node] {
+			return
+		}
+		framed[node] = true
+		acts := code[node]
+		for n := range acts {
+			act := &acts[n]
+			switch act.Type {
+			case "func", "<lambda>":
+				inner := &Frame{Index: make(map[string]int32)}
+				for _, v := range act.Variables {
+					if _, node := code[string(v)]; node {
+						walk(string(v), inner)
+					}
+				}
+				if act.Type == "func" {
+					walk(act.Target, inner)
+				}
+			default:
+				if _, node := code[act.Target]; node {
+					walk(act.Target, f)
+				}
+				for _, v := range act.Variables {
+					if _, node := code[string(v)]; node {
+						walk(string(v), f)
+					}
+				}
+			}
+			act.Frame = f
+			act.Syms = make([]int32, 1+len(act.Variables))
+			act.Syms[0] = sym(f, act.Target)
+			for m, v := range act.Variables {
+				act.Syms[1+m] = sym(f, string(v))
+			}
+		}
+	}
+	walk(entry, &Frame{Index: make(map[string]int32)})
+}
+
+// SLOTS END
@@ -0,0 +1,48 @@
+package bytecode
+
+import "testing"
+
+func TestResolveFrames(t *testing.T) {
+	unit := GetCode("x = 1\nfunc f a:\n    y = a + x\n    return y\n!f x")
+	top := unit.Code[unit.Entry]
+	var body string
+	for _, act := range top {
+		if act.Type == "func" {
+			body = act.Target
+		}
+	}
+	if body == "" {
+		t.Fatal("no func action at the top level")
+	}
+	frames := map[*Frame]bool{}
+	for node, frame := range map[string]*Frame{unit.Entry: top[0].Frame, body: unit.Code[body][0].Frame} {
+		if frame == nil {
+			t.Fatalf("node %s has no frame", node)
+		}
+		frames[frame] = true
+		for _, act := range unit.Code[node] {
+			if act.Frame != frame {
+				t.Errorf("%s %v of node %s is in another frame", act.Type, act.Variables, node)
+			}
+			if len(act.Syms) != 1+len(act.Variables) {
+				t.Fatalf("%s %v has %d symbols", act.Type, act.Variables, len(act.Syms))
+			}
+			for n, sym := range act.Syms {
+				name := act.Target
+				if n > 0 {
+					name = string(act.Variables[n-1])
+				}
+				if sym == NoSymbol {
+					if _, node := unit.Code[name]; !node && name != "" {
+						t.Errorf("%q of %s has no symbol", name, act.Type)
+					}
+				} else if frame.Names[sym] != name {
+					t.Errorf("symbol %d of %s is %q, want %q", sym, act.Type, frame.Names[sym], name)
+				}
+			}
+		}
+	}
+	if len(frames) != 2 {
+		t.Error("the function body shares the frame of the top level")
+	}
+}
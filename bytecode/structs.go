@@ -0,0 +1,80 @@
+package bytecode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// STRUCTS START
+
+// A struct statement lowers to a struct action whose target is the node of
+// its block and whose variables are the name of the struct followed by its
+// fields, written as the parameters of its constructor (see ParseParams) with
+// "name.type" for a field of a declared type. The block only holds the func
+// statements of the methods, each taking the instance as its first parameter.
+
+// Struct is a type declared by a struct statement. Its instances are pairs of
+// the fields by name that point back to it.
+type Struct struct {
+	Name    string
+	Fields  []Param  // the parameters of its constructor, in order
+	Types   []string // the declared type of each field, "" when any value goes
+	Methods map[string]*Function
+}
+
+// ParseFields reads the fields of a struct action and their types.
+func ParseFields(vs []Variable) ([]Param, []string) {
+	fields := ParseParams(vs)
+	types := make([]string, len(fields))
+	for n := range fields {
+		fields[n].Name, types[n], _ = strings.Cut(fields[n].Name, ".")
+	}
+	return fields, types
+}
+
+// Field returns the index of the field name, -1 when s has no such field.
+func (s *Struct) Field(name string) int {
+	for n, field := range s.Fields {
+		if field.Name == name {
+			return n
+		}
+	}
+	return -1
+}
+
+// checkStructs reports the statements of struct blocks other than methods,
+// methods without a parameter for the instance and names used both for a
+// field and a method.
+func checkStructs(code map[string][]Action) []SyntaxError {
+	errs := []SyntaxError{}
+	failed := map[*SourceLine]bool{} // a statement of several actions fails once
+	fail := func(act Action, format string, args ...any) {
+		if act.Source != nil && !failed[act.Source] {
+			failed[act.Source] = true
+			errs = append(errs, SyntaxError{Line: act.Source.N + 1, Column: act.Source.Col, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+	for _, acts := range code {
+		for _, act := range acts {
+			if act.Type != "struct" {
+				continue
+			}
+			fields, _ := ParseFields(act.Variables[1:])
+			s := &Struct{Fields: fields}
+			for _, method := range code[act.Target] {
+				switch {
+				case method.Type == "##" || method.Type == "GC":
+				case method.Type != "func":
+					fail(method, "the block of struct %s can only declare its methods", act.First())
+				case len(method.Variables) < 2 || strings.HasPrefix(string(method.Variables[1]), "..."):
+					fail(method, "method %s.%s must take the instance as its first parameter", act.First(), method.First())
+				case s.Field(method.First()) > -1:
+					fail(method, "%q is both a field and a method of struct %s", method.First(), act.First())
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// STRUCTS END
@@ -32,9 +32,49 @@ def to_tk(text, ind):
         ind-=1
     return f"{lines}.{chars-1}"
 
+def comment_spans(text):
+    """(start, end) offsets of the comments of text: # up to the end of the line
+    and nesting #[ ... ]# blocks, skipping the string literals"""
+    spans = []
+    i = 0
+    while i < len(text):
+        if text[i] == '"' or text[i] == "r" and text[i+1:i+2] == '"' and not (i and (text[i-1].isalnum() or text[i-1] == "_")):
+            raw = text[i] == "r"
+            i += raw
+            quote = '"""' if text.startswith('"""', i) else '"'
+            i += len(quote)
+            while i < len(text) and not text.startswith(quote, i):
+                if text[i] == "\n" and quote == '"':
+                    break
+                i += 2 if text[i] == "\\" and not raw else 1
+            i += len(quote)
+        elif text.startswith("#[", i):
+            start, depth = i, 0
+            while i < len(text):
+                if text.startswith("#[", i):
+                    depth += 1
+                    i += 2
+                elif text.startswith("]#", i):
+                    depth -= 1
+                    i += 2
+                    if depth == 0:
+                        break
+                else:
+                    i += 1
+            spans.append((start, i))
+        elif text[i] == "#":
+            end = text.find("\n", i)
+            end = len(text) if end < 0 else end
+            spans.append((i, end))
+            i = end
+        else:
+            i += 1
+    return spans
+
 def remove_comment(line):
-    if "@" in line:
-        return line.split("@")[0]
+    spans = comment_spans(line)
+    if spans:
+        return line[:spans[0][0]]
     else:
         return line
 
@@ -178,7 +218,7 @@ class GUI(Tk):
         self.pfuns = ["mark","proc","print","out","prec","return","sleep", "env", "except","quit"]
         self.funs = ["sort","format", "join", "len", "list", "append", "pop", "convert", "sub", "type", "system", "input", "has", "range", "index", "run", "read", "write", "array", "itc", "cti", "rand", "checkpoint", "help", "exec", "pair", "keys", "ternary", "id", "value"]
         #self.funs = [f"{f} " for f in self.funs]
-        self.synt = ["or","not","and","true","false","if", "while", "pool", "<-", "->", "set", "for", "else", "func", "--", "source", "library", "error", "switch", "case", "process", "global", "repeat"]
+        self.synt = ["or","not","and","true","false","if", "while", "pool", "<-", "->", "set", "for", "else", "func", "--", "source", "library", "error", "switch", "case", "process", "global", "repeat", "import", "as", "struct"]
         self.exts = [("Minimum file","*.min"),("Plain text file","*.txt"),("Other","*.*")]
         self.t_editor.tag_config("funs", foreground = "#00D413")
         self.t_editor.tag_config("pfuns", foreground = "#C48A00")
@@ -436,12 +476,13 @@ class GUI(Tk):
                                  ("funs",f"\\b({'|'.join(self.funs)})\\b"),
                                  ("pfuns",f"\\b({'|'.join(self.pfuns)})\\b"),
                                  ("str",r'\"(\\.|[^\"])*\"'),
-                                 ("str","#.*$"),
                                  ("ufuns", f"\\b({'|'.join(self.user_funs)})\\b")]:
                 for obj in re.finditer(pattern, string):
                     start = f"{line}.{obj.start(0)}"
                     end = f"{line}.{obj.end(0)}"
                     self.t_editor.tag_add(tag, start, end)
+        for start, end in comment_spans(self.t_editor.get("1.0","end-1c")):
+            self.t_editor.tag_add("str", f"1.0+{start}c", f"1.0+{end}c")
 
     def highlight_manager(self):
         [self.t_editor.tag_remove(tag, "1.0", END) for tag in ["pfuns","funs","synt","str","ufuns"]]
@@ -468,8 +509,8 @@ class GUI(Tk):
         for i, line in enumerate(text):
             for match in re.finditer(pattern, line):
                 matches.append((f"{i + 1}.{match.start()}", f"{i + 1}.{match.end()}"))
-            if "@" in '""'.join(line.split('"')[::2]):
-                matches.append((f"{i + 1}.{line.find('@')}", f"{i + 1}.end"))
+        for start, end in comment_spans(self.t_editor.get("1.0", "end-1c")):
+            matches.append((f"1.0+{start}c", f"1.0+{end}c"))
         for start, end in matches:
             self.t_editor.tag_add("str", start, end)
 
@@ -0,0 +1,176 @@
+package inter
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"minimum/bytecode"
+	"slices"
+)
+
+// ARGUMENTS START
+
+// invoke calls the user function fn for the action and saves what it returns
+// in the target. A method taken from an instance writes the instance back to
+// the variable it was taken from, so that the changes it made to it last, and
+// when that variable is also the target it gets whatever self was left with.
+func (in *Interpreter) invoke(fn *bytecode.Function, action bytecode.Action) bool {
+	f_in := Interpreter{V: &Vars{
+		Names: make(map[string]int),
+	}}
+	f_in.Id = rand.Uint64()
+	f_in.Copy(in)
+	f_in.enter(fn)
+	f_in.fn, f_in.call = fn.Name, action.Source
+	if in.bind(&f_in, fn, action) {
+		return true
+	}
+	err := f_in.Run(fn.Node)
+	in.Err = f_in.Err
+	if err {
+		return err
+	}
+	if _, ok := f_in.V.Names["_return_"]; !ok {
+		f_in.Nothing("_return_")
+	}
+	in.Save(action.Target, in.returned(&f_in))
+	if fn.Recv != "" {
+		if self := fn.Params[0].Name; fn.Recv == action.Target || f_in.Type(self) == PAIR && f_in.NamedPair(self).Struct == fn.Struct {
+			in.Save(fn.Recv, in.carry(&f_in, self))
+		}
+	}
+	f_in.Destroy()
+	return false
+}
+
+// bind gives the parameters of the user function fn their values in f_in,
+// the scope of the call action: the positional arguments in order, then the
+// named ones and the list of the positional ones left over for the rest
+// parameter. Every parameter still missing runs the node of its default
+// value in f_in, in the order they are declared. A missing, unknown or
+// repeated argument fails with an "arg_count" error. A method taken from an
+// instance gets it as its first positional argument.
+func (in *Interpreter) bind(f_in *Interpreter, fn *bytecode.Function, action bytecode.Action) bool {
+	params := fn.Params
+	if params == nil {
+		for _, v := range fn.Vars {
+			params = append(params, bytecode.Param{Name: string(v)})
+		}
+	}
+	positional, names, named := bytecode.SplitArgs(action.Variables)
+	self := 0
+	if fn.Recv != "" {
+		positional, self = append([]bytecode.Variable{bytecode.Variable(fn.Recv)}, positional...), 1
+	}
+	args := make(map[string]bytecode.Variable)
+	n := 0
+	for _, param := range params {
+		switch {
+		case param.Rest:
+			rest := bytecode.List{}
+			for _, v := range positional[n:] {
+				ListAppend(&rest, f_in, in.GetAny(string(v)))
+			}
+			f_in.Save(param.Name, rest)
+			n = len(positional)
+		case n < len(positional):
+			args[param.Name] = positional[n]
+			n++
+		}
+	}
+	if n < len(positional) {
+		in.Error(action, fmt.Sprintf("%d positional arguments were provided, %s takes at most %d!", len(positional)-self, fn.Name, n-self), "arg_count")
+		return true
+	}
+	for m, name := range names {
+		at := slices.IndexFunc(params, func(p bytecode.Param) bool { return p.Name == name && !p.Rest })
+		if at < 0 {
+			in.Error(action, fmt.Sprintf("%s has no parameter %q!", fn.Name, name), "arg_count")
+			return true
+		}
+		if _, ok := args[name]; ok {
+			in.Error(action, fmt.Sprintf("%s got the argument %q twice!", fn.Name, name), "arg_count")
+			return true
+		}
+		args[name] = named[m]
+	}
+	for _, param := range params {
+		v, ok := args[param.Name]
+		switch {
+		case ok:
+			f_in.Save(param.Name, f_in.carry(in, string(v)))
+		case param.Rest:
+		case param.Default == "":
+			in.Error(action, fmt.Sprintf("%s is missing the argument %q!", fn.Name, param.Name), "arg_count")
+			return true
+		case f_in.Run(param.Default):
+			in.Err = f_in.Err
+			return true
+		}
+	}
+	return false
+}
+
+// callback runs the user function fn for a builtin such as map or sort, the
+// way a call passing item as its only argument would, and returns the scope
+// of the call to read what it returned from.
+func (in *Interpreter) callback(fn *bytecode.Function, action bytecode.Action, item any) (*Interpreter, bool) {
+	// the item is passed by name from a scope of its own
+	a_in := Interpreter{V: &Vars{
+		Names: make(map[string]int),
+	}}
+	a_in.Id = rand.Uint64()
+	a_in.Copy(in)
+	a_in.Save("_item_", item)
+	f_in := &Interpreter{V: &Vars{
+		Names: make(map[string]int),
+	}}
+	f_in.Id = rand.Uint64()
+	f_in.Copy(in)
+	f_in.enter(fn)
+	f_in.fn, f_in.call = fn.Name, action.Source
+	call := bytecode.Action{Target: "_return_", Type: fn.Name, Variables: []bytecode.Variable{"_item_"}, Source: action.Source}
+	if a_in.bind(f_in, fn, call) {
+		in.Err = a_in.Err
+		return nil, true
+	}
+	a_in.Destroy()
+	if f_in.Run(fn.Node) {
+		in.Err = f_in.Err
+		return nil, true
+	}
+	return f_in, false
+}
+
+// defaults gives the parameters of fn from the index from on their default
+// values, or the empty list for the rest parameter. The constructor of a
+// struct with an init method runs it for the fields, which start as Nothing,
+// so a field without a default keeps Nothing.
+func (in *Interpreter) defaults(fn *bytecode.Function, from int) bool {
+	for _, param := range fn.Params[min(from, len(fn.Params)):] {
+		switch {
+		case param.Rest:
+			in.Save(param.Name, bytecode.List{})
+		case param.Default != "" && in.Run(param.Default):
+			return true
+		}
+	}
+	return false
+}
+
+// carry returns the value of the variable name of from for in, with its own
+// copy of a list, pair or span.
+func (in *Interpreter) carry(from *Interpreter, name string) any {
+	switch from.Type(name) {
+	case PAIR:
+		return in.CopyPair(name, from)
+	case LIST:
+		return in.CopyList(name, from)
+	case ID:
+		return from.NamedId(name)
+	case SPAN:
+		return in.CopySpan(name, from)
+	}
+	return from.GetAny(name)
+}
+
+// ARGUMENTS END
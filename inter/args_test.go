@@ -0,0 +1,43 @@
+package inter
+
+import "testing"
+
+func TestMapLambdaWithoutParameters(t *testing.T) {
+	runErr(t, "l = [1, 2]\nm = !map l, func : 3\n!print m", "arg_count")
+}
+
+func TestMapRestParameter(t *testing.T) {
+	out := runOk(t, "l = [1, 2]\nm = !map l, func ...xs: xs\n!print m")
+	if out != "[[1], [2]]\n" {
+		t.Errorf("map with a rest parameter printed %q, want %q", out, "[[1], [2]]\n")
+	}
+}
+
+func TestMapRequiredParameters(t *testing.T) {
+	runErr(t, "l = [1, 2]\nm = !map l, func a, b: a\n!print m", "arg_count")
+}
+
+func TestMapDefaultParameter(t *testing.T) {
+	out := runOk(t, "l = [1, 2]\nm = !map l, func a, b = 10: a + b\n!print m")
+	if out != "[11, 12]\n" {
+		t.Errorf("map with a default parameter printed %q, want %q", out, "[11, 12]\n")
+	}
+}
+
+func TestSortRequiredParameters(t *testing.T) {
+	runErr(t, "l = [2, 1]\ns = !sort l, func a, b: a\n!print s", "arg_count")
+}
+
+func TestIncrementEnclosing(t *testing.T) {
+	for _, c := range []struct {
+		source, want string
+	}{
+		{"n = 0\nfunc inc:\n    n++\n    return n\n!print (!inc)\n!print (!inc)", "1\n2\n"},
+		{"func counter:\n    n = 10\n    func next:\n        n--\n        return n\n    return next\ntick = !counter\n!print !tick, !tick", "9 8\n"},
+	} {
+		if out := runOk(t, c.source); out != c.want {
+			t.Errorf("%q printed %q, want %q", c.source, out, c.want)
+		}
+	}
+	runErr(t, "func inc:\n    missing++\n!inc", "undeclared")
+}
@@ -0,0 +1,100 @@
+package inter
+
+import (
+	"minimum/bytecode"
+	"path/filepath"
+	"strings"
+)
+
+// CAPABILITIES START
+
+// Capabilities lists the side effects a script may cause. An interpreter
+// without capabilities (nil) may do anything.
+type Capabilities struct {
+	Read   PathSet // read, source, isdir, stats, glob, chdir
+	Write  PathSet // write, mkdir, remove
+	Exec   bool    // $ and $$ commands
+	Net    bool    // rget and rpost
+	Env    bool    // env
+	Native bool    // library
+}
+
+// PathSet allows filesystem access to everything when All is set, otherwise
+// only below one of Paths. Paths are made absolute when parsed, so a later
+// !chdir does not widen the set; symbolic links are not resolved.
+type PathSet struct {
+	All   bool
+	Paths []string
+}
+
+// DefaultCapabilities are given to every interpreter created by NewInterpreter
+// and friends, nil meaning unrestricted.
+var DefaultCapabilities *Capabilities
+
+// ParsePaths turns a comma separated list of paths into a PathSet, an empty
+// list allowing every path.
+func ParsePaths(list string) PathSet {
+	if strings.TrimSpace(list) == "" {
+		return PathSet{All: true}
+	}
+	set := PathSet{}
+	for _, path := range strings.Split(list, ",") {
+		if abs, err := filepath.Abs(strings.TrimSpace(path)); err == nil {
+			set.Paths = append(set.Paths, abs)
+		}
+	}
+	return set
+}
+
+// Allows reports whether path lies inside the set.
+func (set PathSet) Allows(path string) bool {
+	if set.All {
+		return true
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range set.Paths {
+		rel, err := filepath.Rel(prefix, abs)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (caps *Capabilities) allows(capability, path string) bool {
+	switch capability {
+	case "read":
+		return caps.Read.Allows(path)
+	case "write":
+		return caps.Write.Allows(path)
+	case "exec":
+		return caps.Exec
+	case "net":
+		return caps.Net
+	case "env":
+		return caps.Env
+	case "native":
+		return caps.Native
+	}
+	return false
+}
+
+// forbidden raises a "permission" error unless the interpreter holds the
+// capability (read, write, exec, net, env or native). The path is only
+// checked for read and write.
+func (in *Interpreter) forbidden(action bytecode.Action, capability, path string) bool {
+	if in.Caps == nil || in.Caps.allows(capability, path) {
+		return false
+	}
+	message := "no " + capability + " permission"
+	if path != "" {
+		message += " for " + path
+	}
+	in.Error(action, message, "permission")
+	return true
+}
+
+// CAPABILITIES END
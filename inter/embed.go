@@ -0,0 +1,242 @@
+package inter
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"minimum/bytecode"
+	"sort"
+	"strings"
+)
+
+// EMBEDDING START
+
+// Script is a compiled Minimum program together with the interpreter that
+// owns its globals. It is the supported way of hosting Minimum inside a Go
+// program: compile once, run, then exchange globals as native Go values.
+type Script struct {
+	in    *Interpreter
+	entry string
+}
+
+// Compile turns the source into a runnable script. The file name is only used
+// for `!system "file"` and error reports.
+func Compile(source, file string) (*Script, error) {
+	unit, err := bytecode.Compile(source)
+	if err != nil {
+		return nil, fmt.Errorf("syntax error in %s: %w", file, err)
+	}
+	compiled := NewCompiledInterpreter(unit, file)
+	in := &compiled
+	in.IgnoreErr = true // errors are handed back to the host instead of printed
+	return &Script{in: in, entry: in.Entry}, nil
+}
+
+// Interpreter exposes the underlying interpreter for hosts that need lower
+// level access.
+func (s *Script) Interpreter() *Interpreter {
+	return s.in
+}
+
+// Run executes the top level of the script. Globals assigned by the script
+// stay available through Get after Run returns.
+func (s *Script) Run() error {
+	s.in.Err = nil
+	if failed := s.in.Run(s.entry); failed {
+		return s.in.runtimeError()
+	}
+	return nil
+}
+
+// RunContext is Run stopping with an "interrupt" or "timeout" RuntimeError
+// once ctx is cancelled or its deadline passes.
+func (s *Script) RunContext(ctx context.Context) error {
+	s.in.Err = nil
+	if failed := s.in.RunContext(ctx, s.entry); failed {
+		return s.in.runtimeError()
+	}
+	return nil
+}
+
+// SetLimits restricts the resources used by the following runs, exceeding
+// one of them fails the run with a "quota" RuntimeError.
+func (s *Script) SetLimits(limits Limits) {
+	s.in.SetLimits(limits)
+}
+
+// SetCapabilities restricts the side effects of the script, nil allowing all
+// of them. Scripts are unrestricted unless DefaultCapabilities is set.
+func (s *Script) SetCapabilities(caps *Capabilities) {
+	s.in.Caps = caps
+}
+
+// Get returns the global called name converted to a native Go value, see ToGo.
+func (s *Script) Get(name string) (any, error) {
+	if _, ok := s.in.V.Names[name]; !ok {
+		return nil, fmt.Errorf("undeclared variable: %s", name)
+	}
+	return s.in.ToGo(s.in.GetAny(name)), nil
+}
+
+// Set stores a native Go value as the global called name, see FromGo.
+func (s *Script) Set(name string, value any) error {
+	v, err := s.in.FromGo(value)
+	if err != nil {
+		return err
+	}
+	s.in.Save(name, v)
+	return nil
+}
+
+func (in *Interpreter) runtimeError() *RuntimeError {
+	if in.Err == nil {
+		return &RuntimeError{Type: "unknown", Message: "execution failed"}
+	}
+	return in.Err
+}
+
+// FromGo converts a native Go value into its Minimum representation, storing
+// nested list and pair items inside this interpreter. Supported inputs are
+// nil, integers, floats, string, bool, byte, *big.Int, *big.Float, []any,
+// []string, map[string]any and functions returned by ToGo.
+func (in *Interpreter) FromGo(value any) (any, error) {
+	switch v := value.(type) {
+	case nil:
+		return int16(0), nil
+	case int:
+		return big.NewInt(int64(v)), nil
+	case int8:
+		return big.NewInt(int64(v)), nil
+	case int16:
+		return big.NewInt(int64(v)), nil
+	case int32:
+		return big.NewInt(int64(v)), nil
+	case int64:
+		return big.NewInt(v), nil
+	case uint:
+		return new(big.Int).SetUint64(uint64(v)), nil
+	case uint16:
+		return big.NewInt(int64(v)), nil
+	case uint32:
+		return big.NewInt(int64(v)), nil
+	case uint64:
+		return new(big.Int).SetUint64(v), nil
+	case byte:
+		return v, nil
+	case float32:
+		return big.NewFloat(float64(v)), nil
+	case float64:
+		return big.NewFloat(v), nil
+	case *big.Int:
+		return new(big.Int).Set(v), nil
+	case *big.Float:
+		return new(big.Float).Copy(v), nil
+	case string, bool, *bytecode.Function:
+		return v, nil
+	case []string:
+		l := bytecode.List{}
+		for _, item := range v {
+			ListAppend(&l, in, item)
+		}
+		return l, nil
+	case []any:
+		l := bytecode.List{}
+		for n, item := range v {
+			converted, err := in.FromGo(item)
+			if err != nil {
+				return nil, fmt.Errorf("list item %d: %w", n, err)
+			}
+			ListAppend(&l, in, converted)
+		}
+		return l, nil
+	case map[string]any:
+		p := bytecode.Pair{Ids: make(map[string]*bytecode.MinPtr)}
+		for key, item := range v {
+			converted, err := in.FromGo(item)
+			if err != nil {
+				return nil, fmt.Errorf("pair key %q: %w", key, err)
+			}
+			PairAppend(&p, in, converted, key)
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("unsupported Go type %T", value)
+}
+
+// ToGo converts a Minimum value (as returned by GetAny or GetAnyRef) into a
+// native Go value: int64 (or *big.Int when it does not fit), float64, string,
+// bool, byte, []any, map[string]any, []byte for byte spans and nil for
+// Nothing. Functions and ids are returned unchanged.
+func (in *Interpreter) ToGo(value any) any {
+	switch v := value.(type) {
+	case int16:
+		return nil
+	case *big.Int:
+		if v.IsInt64() {
+			return v.Int64()
+		}
+		return new(big.Int).Set(v)
+	case *big.Float:
+		f, _ := v.Float64()
+		return f
+	case bytecode.List:
+		items := make([]any, 0, len(v.Ids))
+		for _, ptr := range v.Ids {
+			items = append(items, in.ToGo(in.GetAnyRef(ptr)))
+		}
+		return items
+	case bytecode.Pair:
+		m := make(map[string]any, len(v.Ids))
+		for key, ptr := range v.Ids {
+			splitted := strings.SplitN(key, ":", 2)
+			m[splitted[1]] = in.ToGo(in.GetAnyRef(ptr))
+		}
+		return m
+	case bytecode.Span:
+		return in.spanToGo(v)
+	}
+	return value
+}
+
+func (in *Interpreter) spanToGo(s bytecode.Span) any {
+	if s.Dtype == BYTE {
+		return append([]byte{}, in.V.Bytes[s.Start:s.Start+s.Length]...)
+	}
+	items := make([]any, 0, s.Length)
+	for n := s.Start; n < s.Start+s.Length; n++ {
+		switch s.Dtype {
+		case INT:
+			items = append(items, in.ToGo(in.V.Ints[n]))
+		case FLOAT:
+			items = append(items, in.ToGo(in.V.Floats[n]))
+		case STR:
+			items = append(items, in.V.Strs[n])
+		case BOOL:
+			items = append(items, in.V.Bools[n])
+		case LIST:
+			items = append(items, in.ToGo(in.V.Lists[n]))
+		case PAIR:
+			items = append(items, in.ToGo(in.V.Pairs[n]))
+		}
+	}
+	return items
+}
+
+// Globals lists the names of the user defined globals, skipping builtins and
+// compiler temporaries.
+func (s *Script) Globals() []string {
+	names := []string{}
+	for name, slot := range s.in.V.Names {
+		if name == "" || strings.HasPrefix(name, "_") || name == "Nothing" {
+			continue
+		}
+		if s.in.V.Slots[slot].Type == FUNC && s.in.V.Funcs[s.in.V.Slots[slot].Index].Node == "" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EMBEDDING END
@@ -0,0 +1,278 @@
+package inter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// compile compiles source as a script, failing the test on a syntax error.
+func compile(t *testing.T, source string) *Script {
+	t.Helper()
+	script, err := Compile(source, "test.min")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func TestGoValues(t *testing.T) {
+	for _, c := range []struct {
+		in, want any
+	}{
+		{nil, nil},
+		{7, int64(7)},
+		{int64(-3), int64(-3)},
+		{uint16(5), int64(5)},
+		{2.5, 2.5},
+		{"text", "text"},
+		{true, true},
+		{byte(9), byte(9)},
+		{[]string{"a", "b"}, []any{"a", "b"}},
+		{[]any{1, "x", []any{false}}, []any{int64(1), "x", []any{false}}},
+		{map[string]any{"n": 1, "l": []any{2.0}}, map[string]any{"n": int64(1), "l": []any{2.0}}},
+	} {
+		script := compile(t, "copy = value")
+		if err := script.Set("value", c.in); err != nil {
+			t.Fatalf("Set(%#v): %v", c.in, err)
+		}
+		if err := script.Run(); err != nil {
+			t.Fatalf("running with %#v: %v", c.in, err)
+		}
+		got, err := script.Get("copy")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%#v came back as %#v, want %#v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestGoValuesUnsupported(t *testing.T) {
+	script := compile(t, "")
+	for _, value := range []any{struct{}{}, []int{1}, map[string]int{"a": 1}, []any{1, struct{}{}}} {
+		if err := script.Set("value", value); err == nil {
+			t.Errorf("Set(%#v) accepted an unsupported type", value)
+		}
+	}
+}
+
+func TestScriptRun(t *testing.T) {
+	script := compile(t, "total = base * 2")
+	if err := script.Set("base", 21); err != nil {
+		t.Fatal(err)
+	}
+	if err := script.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if total, _ := script.Get("total"); total != int64(42) {
+		t.Errorf("total = %#v, want 42", total)
+	}
+	if _, err := script.Get("missing"); err == nil {
+		t.Error("Get of an undeclared global succeeded")
+	}
+}
+
+func TestScriptRuntimeError(t *testing.T) {
+	err := compile(t, "x = 1\n!len x").Run()
+	rerr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("Run returned %#v, want a *RuntimeError", err)
+	}
+	if rerr.Type != "arg_type" {
+		t.Errorf("the error type is %q, want arg_type", rerr.Type)
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	twice := func(args []Value) (Value, error) { return args[0].(int64) * 2, nil }
+	for _, c := range []struct {
+		source, etype string
+	}{
+		{"y = !twice 4", ""},
+		{"y = !twice \"a\"", "arg_type"},
+		{"y = !twice", "arg_count"},
+		{"y = !twice 1, 2", "arg_count"},
+		{"y = !boom 1", "host"},
+	} {
+		script := compile(t, c.source)
+		if err := script.Interpreter().RegisterFunc("twice", "int", twice); err != nil {
+			t.Fatal(err)
+		}
+		boom := func(args []Value) (Value, error) { return args[0].(string), nil } // a bad assertion
+		if err := script.Interpreter().RegisterFunc("boom", "any", boom); err != nil {
+			t.Fatal(err)
+		}
+		err := script.Run()
+		if c.etype == "" {
+			if err != nil {
+				t.Errorf("%q: %v", c.source, err)
+			} else if y, _ := script.Get("y"); y != int64(8) {
+				t.Errorf("%q set y to %#v, want 8", c.source, y)
+			}
+			continue
+		}
+		if rerr, ok := err.(*RuntimeError); !ok || rerr.Type != c.etype {
+			t.Errorf("%q returned %v, want a %s error", c.source, err, c.etype)
+		}
+	}
+}
+
+func TestRegisterFuncSignature(t *testing.T) {
+	script := compile(t, "")
+	noop := func(args []Value) (Value, error) { return nil, nil }
+	for _, signature := range []string{"", "int", "int, str|noth?", "number...", "any, float?, list?"} {
+		if err := script.Interpreter().RegisterFunc("ok", signature, noop); err != nil {
+			t.Errorf("signature %q: %v", signature, err)
+		}
+	}
+	for _, signature := range []string{"integer", "int?, str", "int..., str"} {
+		if err := script.Interpreter().RegisterFunc("bad", signature, noop); err == nil {
+			t.Errorf("signature %q was accepted", signature)
+		}
+	}
+	if err := script.Interpreter().RegisterFunc("print", "any", noop); err == nil {
+		t.Error("a builtin was overridden")
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	loop := "x = 0\nwhile true:\n    x = x + 1"
+	expired, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	cancelled, stop := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		stop()
+	}()
+	for _, c := range []struct {
+		ctx   context.Context
+		etype string
+	}{
+		{expired, "timeout"},
+		{cancelled, "interrupt"},
+	} {
+		done := make(chan error, 1)
+		go func() { done <- compile(t, loop).RunContext(c.ctx) }()
+		select {
+		case err := <-done:
+			if rerr, ok := err.(*RuntimeError); !ok || rerr.Type != c.etype {
+				t.Errorf("the loop stopped with %v, want a %s error", err, c.etype)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the loop did not stop with a %s error", c.etype)
+		}
+	}
+}
+
+func TestRunContextCaught(t *testing.T) {
+	// the error block handling the first interrupt gets to run, a loop in it
+	// is stopped anyway
+	script := compile(t, "error failed:\n    while true:\n        x = 1\nhandled = failed\nwhile true:\n    x = 2")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := script.RunContext(ctx)
+	if rerr, ok := err.(*RuntimeError); !ok || rerr.Type != "timeout" {
+		t.Fatalf("the script stopped with %v, want a timeout error", err)
+	}
+	if handled, _ := script.Get("handled"); handled != true {
+		t.Errorf("handled = %#v, the error block did not catch the timeout", handled)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	for _, c := range []struct {
+		name, source string
+		limits       Limits
+	}{
+		{"actions", "x = 0\nwhile true:\n    x = x + 1", Limits{Actions: 1000}},
+		{"heap", "x = 1\ny = 2", Limits{Heap: 10}},
+		{"depth", "func down n:\n    return !down n + 1\n!down 0", Limits{Depth: 50}},
+		{"output", "!out \"quota\"", Limits{Output: 2}},
+	} {
+		script := compile(t, c.source)
+		script.SetLimits(c.limits)
+		err := script.Run()
+		if rerr, ok := err.(*RuntimeError); !ok || rerr.Type != "quota" {
+			t.Errorf("%s: the script stopped with %v, want a quota error", c.name, err)
+		}
+	}
+	// unlimited fields stay unlimited
+	script := compile(t, "x = 0\nrepeat 100:\n    x = x + 1")
+	script.SetLimits(Limits{Depth: 5})
+	if err := script.Run(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	allowed, denied := t.TempDir(), t.TempDir()
+	for _, dir := range []string{allowed, denied} {
+		if err := os.WriteFile(filepath.Join(dir, "data.txt"), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	caps := &Capabilities{Read: ParsePaths(allowed)}
+	for _, c := range []struct {
+		source, etype string
+	}{
+		{fmt.Sprintf("d = !read %q", filepath.Join(allowed, "data.txt")), ""},
+		{fmt.Sprintf("d = !read %q", filepath.Join(denied, "data.txt")), "permission"},
+		{fmt.Sprintf("!write %q, \"x\"", filepath.Join(allowed, "out.txt")), "permission"},
+		{"$ echo denied", "permission"},
+		{"d = $$ echo denied", "permission"},
+	} {
+		script := compile(t, c.source)
+		script.SetCapabilities(caps)
+		err := script.Run()
+		if c.etype == "" {
+			if err != nil {
+				t.Errorf("%q: %v", c.source, err)
+			}
+			continue
+		}
+		if rerr, ok := err.(*RuntimeError); !ok || rerr.Type != c.etype {
+			t.Errorf("%q returned %v, want a %s error", c.source, err, c.etype)
+		}
+	}
+}
+
+func TestStreams(t *testing.T) {
+	for _, c := range []struct {
+		source, stdin, stdout string
+	}{
+		{"!print 1, \"a\"\n!out 2", "", "1 a\n2"},
+		{"func show x:\n    !print x\n!show 3", "", "3\n"},
+		{"$ echo shell", "", "shell\n"},
+		{"name = !input \"name: \"\n!print \"hi \" + name", "min\n", "name: hi min\n"},
+	} {
+		script := compile(t, c.source)
+		stdout := &strings.Builder{}
+		script.Interpreter().Stdout = stdout
+		script.Interpreter().Stdin = strings.NewReader(c.stdin)
+		if err := script.Run(); err != nil {
+			t.Errorf("%q: %v", c.source, err)
+		} else if stdout.String() != c.stdout {
+			t.Errorf("%q printed %q, want %q", c.source, stdout.String(), c.stdout)
+		}
+	}
+}
+
+func TestStreamsLimited(t *testing.T) {
+	script := compile(t, "!out \"quota\"")
+	stdout := &strings.Builder{}
+	script.Interpreter().Stdout = stdout
+	script.SetLimits(Limits{Output: 2})
+	if err := script.Run(); err == nil {
+		t.Fatal("the output limit was not hit")
+	}
+	if stdout.String() != "qu" {
+		t.Errorf("printed %q, want the output cut at the limit", stdout.String())
+	}
+}
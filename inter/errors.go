@@ -0,0 +1,184 @@
+package inter
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"minimum/bytecode"
+	"strings"
+)
+
+// ERRORS START
+
+// RuntimeError describes a failed action. Type holds the same error type
+// string that scripts see in the `info` pair of an `error` statement
+// (arg_type, index, undeclared, ...).
+type RuntimeError struct {
+	Type    string
+	Message string
+	Action  string
+	File    string
+	Line    int
+	Column  int
+	Source  string
+	Trace   []Frame // active calls, outermost first, the last one raised the error
+	Payload any     // the pair given to `!except`, as returned by ToGo
+}
+
+// Frame is one entry of a RuntimeError trace: the line being run by a
+// function, "" standing for the top level.
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+	Column   int
+	Source   string
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("%s error on line %d: %s", e.Type, e.Line, e.Message)
+}
+
+// Traceback renders the error the way the interpreter reports it.
+func (e *RuntimeError) Traceback() string {
+	b := strings.Builder{}
+	b.WriteString("Traceback (most recent call last):\n")
+	for _, f := range e.Trace {
+		name := f.Function
+		if name == "" {
+			name = "<main>"
+		}
+		fmt.Fprintf(&b, "  File \"%s\", line %d, column %d, in %s\n", f.File, f.Line, f.Column, name)
+		fmt.Fprintf(&b, "    %s\n", strings.ReplaceAll(f.Source, "\n", "\n    "))
+	}
+	fmt.Fprintf(&b, "Runtime error: %s\nAction: %s\nType: %s\n", e.Message, e.Action, e.Type)
+	return b.String()
+}
+
+// Matches reports whether an error statement with the given variables
+// catches the error. String constants among them list the caught types,
+// without any every error is caught.
+func (e *RuntimeError) Matches(vars []bytecode.Variable) bool {
+	filtered := false
+	for _, v := range vars {
+		name := string(v)
+		if !strings.HasPrefix(name, "\"") {
+			continue
+		}
+		filtered = true
+		if strings.Trim(name, "\"") == e.Type {
+			return true
+		}
+	}
+	return !filtered
+}
+
+// Raise reports the error of the action. The location and trace are filled
+// in unless the error already carries them, as a re-raised one does.
+func (in *Interpreter) Raise(act bytecode.Action, e *RuntimeError) {
+	if e.Trace == nil {
+		e.Trace = in.trace(act.Source)
+		last := e.Trace[len(e.Trace)-1]
+		e.File, e.Line, e.Column, e.Source = last.File, last.Line, last.Column, last.Source
+	}
+	if e.Action == "" {
+		e.Action = act.Type
+	}
+	in.Err = e
+	if !in.IgnoreErr {
+		io.WriteString(in.stderr(), e.Traceback())
+	}
+}
+
+func newFrame(function, file string, sl *bytecode.SourceLine) Frame {
+	f := Frame{Function: function, File: file}
+	if sl != nil {
+		f.Line = sl.N + 1
+		f.Column = sl.Col
+		f.Source = strings.ReplaceAll(sl.Source, "\r\n", "\n")
+	}
+	return f
+}
+
+// trace collects the frames of the calls leading to the action at sl.
+func (in *Interpreter) trace(sl *bytecode.SourceLine) []Frame {
+	frames := []Frame{newFrame(in.fn, in.fileName(), sl)}
+	for scope := in; scope.up() != nil; scope = scope.up() {
+		if scope.call != nil {
+			frames = append(frames, newFrame(scope.up().fn, scope.up().fileName(), scope.call))
+		}
+	}
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return frames
+}
+
+func (in *Interpreter) fileName() string {
+	if in.File == nil {
+		return ""
+	}
+	return *in.File
+}
+
+// errorPair converts the error into the `info` pair of an error statement.
+func (in *Interpreter) errorPair(e *RuntimeError) bytecode.Pair {
+	p := bytecode.Pair{Ids: make(map[string]*bytecode.MinPtr)}
+	PairAppend(&p, in, big.NewInt(int64(e.Line)), "line")
+	PairAppend(&p, in, big.NewInt(int64(e.Column)), "column")
+	PairAppend(&p, in, e.File, "file")
+	PairAppend(&p, in, e.Source, "source")
+	PairAppend(&p, in, e.Action, "action")
+	PairAppend(&p, in, e.Type, "type")
+	PairAppend(&p, in, e.Message, "message")
+	trace := bytecode.List{}
+	for _, f := range e.Trace {
+		frame := bytecode.Pair{Ids: make(map[string]*bytecode.MinPtr)}
+		PairAppend(&frame, in, f.Function, "function")
+		PairAppend(&frame, in, f.File, "file")
+		PairAppend(&frame, in, big.NewInt(int64(f.Line)), "line")
+		PairAppend(&frame, in, big.NewInt(int64(f.Column)), "column")
+		PairAppend(&frame, in, f.Source, "source")
+		ListAppend(&trace, in, frame)
+	}
+	PairAppend(&p, in, trace, "trace")
+	payload, err := in.FromGo(e.Payload)
+	if err != nil {
+		payload = int16(0) // Nothing
+	}
+	PairAppend(&p, in, payload, "payload")
+	return p
+}
+
+// PairError is the reverse of errorPair, turning the `info` pair of an error
+// statement back into the error so it can be raised again.
+func (in *Interpreter) PairError(p bytecode.Pair) (*RuntimeError, error) {
+	m := in.ToGo(p).(map[string]any)
+	text := func(key string) string {
+		s, _ := m[key].(string)
+		return s
+	}
+	number := func(from map[string]any, key string) int {
+		n, _ := from[key].(int64)
+		return int(n)
+	}
+	e := &RuntimeError{Type: text("type"), Message: text("message"), Action: text("action"), File: text("file"), Source: text("source"), Payload: m["payload"]}
+	if e.Type == "" {
+		return nil, fmt.Errorf("error pair without a type")
+	}
+	e.Line, e.Column = number(m, "line"), number(m, "column")
+	frames, _ := m["trace"].([]any)
+	for _, item := range frames {
+		f, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("trace entries must be pairs")
+		}
+		function, _ := f["function"].(string)
+		file, _ := f["file"].(string)
+		source, _ := f["source"].(string)
+		e.Trace = append(e.Trace, Frame{Function: function, File: file, Line: number(f, "line"), Column: number(f, "column"), Source: source})
+	}
+	return e, nil
+}
+
+// ERRORS END
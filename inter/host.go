@@ -0,0 +1,156 @@
+package inter
+
+import (
+	"errors"
+	"fmt"
+	"minimum/bytecode"
+	"strings"
+)
+
+// HOST FUNCTIONS START
+
+// Value is a Minimum value in its native Go form, see ToGo and FromGo.
+type Value = any
+
+// HostFunc is a Go function registered with RegisterFunc.
+type HostFunc struct {
+	Name      string
+	Signature string
+	Call      func(args []Value) (Value, error)
+	min, max  int
+	dtypes    [][]byte // accepted types per argument, nil accepts any
+	variadic  bool
+}
+
+var dtypeNames = map[string][]byte{
+	"noth": {NOTH}, "int": {INT}, "float": {FLOAT}, "str": {STR}, "arr": {ARR},
+	"list": {LIST}, "pair": {PAIR}, "bool": {BOOL}, "byte": {BYTE}, "func": {FUNC},
+	"id": {ID}, "span": {SPAN}, "number": {INT, FLOAT, BYTE}, "any": nil,
+}
+
+// RegisterFunc makes fn callable from scripts as `!name args`. The signature
+// lists the argument types separated by commas, each being a type name
+// (int, float, str, list, pair, bool, byte, span, func, id, noth), several
+// of them joined by `|`, `number` or `any`. A trailing `?` marks an optional
+// argument and a trailing `...` on the last one accepts any number of them:
+//
+//	in.RegisterFunc("html_set", "str, str", set)
+//	in.RegisterFunc("sum", "number...", sum)
+//	in.RegisterFunc("greet", "str, str|noth?", greet)
+//
+// Arguments arrive converted with ToGo and the result is stored with FromGo.
+// Returning a *RuntimeError selects the error type and payload seen by the
+// script, any other error is raised with the "host" type.
+func (in *Interpreter) RegisterFunc(name, signature string, fn func(args []Value) (Value, error)) error {
+	for _, builtin := range bytecode.GenerateFuns() {
+		if builtin.Name == name {
+			return fmt.Errorf("cannot override builtin function: %s", name)
+		}
+	}
+	host, err := parseSignature(signature)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	host.Name = name
+	host.Call = fn
+	if in.Hosts == nil {
+		in.Hosts = make(map[string]*HostFunc)
+	}
+	in.Hosts[name] = host
+	in.Save(name, &bytecode.Function{Name: name})
+	return nil
+}
+
+func parseSignature(signature string) (*HostFunc, error) {
+	host := &HostFunc{Signature: signature}
+	if strings.TrimSpace(signature) == "" {
+		return host, nil
+	}
+	optional := false
+	args := strings.Split(signature, ",")
+	for n, arg := range args {
+		arg = strings.TrimSpace(arg)
+		switch {
+		case strings.HasSuffix(arg, "..."):
+			if n != len(args)-1 {
+				return nil, errors.New("only the last argument can be variadic")
+			}
+			arg = strings.TrimSuffix(arg, "...")
+			host.variadic = true
+		case strings.HasSuffix(arg, "?"):
+			arg = strings.TrimSuffix(arg, "?")
+			optional = true
+		case optional:
+			return nil, fmt.Errorf("required argument %d follows an optional one", n)
+		default:
+			host.min++
+		}
+		var dtypes []byte
+		for _, dname := range strings.Split(arg, "|") {
+			types, ok := dtypeNames[strings.TrimSpace(dname)]
+			if !ok {
+				return nil, fmt.Errorf("unknown type in signature: %q", dname)
+			}
+			if types == nil {
+				dtypes = nil
+				break
+			}
+			dtypes = append(dtypes, types...)
+		}
+		host.dtypes = append(host.dtypes, dtypes)
+	}
+	host.max = len(host.dtypes)
+	if host.variadic {
+		host.max = -1
+	}
+	return host, nil
+}
+
+// argTypes returns the types accepted at position n, nil meaning any.
+func (host *HostFunc) argTypes(n int) []byte {
+	if n >= len(host.dtypes) {
+		return host.dtypes[len(host.dtypes)-1]
+	}
+	return host.dtypes[n]
+}
+
+func (in *Interpreter) callHost(host *HostFunc, action bytecode.Action) bool {
+	if in.CheckArgN(action, host.min, host.max) {
+		return true
+	}
+	args := make([]Value, len(action.Variables))
+	for n, v := range action.Variables {
+		if dtypes := host.argTypes(n); dtypes != nil && in.CheckDtype(action, n, dtypes...) {
+			return true
+		}
+		args[n] = in.ToGo(in.GetAny(string(v)))
+	}
+	result, err := host.safeCall(args)
+	if err == nil {
+		result, err = in.FromGo(result)
+	}
+	if err != nil {
+		var rerr *RuntimeError
+		if errors.As(err, &rerr) {
+			in.Raise(action, &RuntimeError{Type: rerr.Type, Message: rerr.Message, Payload: rerr.Payload})
+		} else {
+			in.Error(action, err.Error(), "host")
+		}
+		return true
+	}
+	in.Save(action.Target, result)
+	return false
+}
+
+// safeCall turns a panicking host function into an ordinary error so that a
+// bad type assertion in Go code does not take the whole interpreter down.
+func (host *HostFunc) safeCall(args []Value) (result Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, fmt.Errorf("%s panicked: %v", host.Name, r)
+		}
+	}()
+	return host.Call(args)
+}
+
+// HOST FUNCTIONS END
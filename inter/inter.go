@@ -2,9 +2,11 @@ package inter
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -22,6 +24,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -58,6 +61,8 @@ type Vars struct {
 	Slots   []Entry        // each entry tells the type of data and its real address
 	Ints    []*big.Int
 	Floats  []*big.Float
+	Smalls  []int64   // machine sized ints, in use where Ints holds nil
+	Doubles []float64 // 53 bit floats, in use where Floats holds nil
 	Strs    []string
 	Bools   []bool
 	Bytes   []byte
@@ -70,6 +75,8 @@ type Vars struct {
 	gcCycle uint16
 	gcMax   uint16
 	gcSize  uint64
+	frame   *bytecode.Frame // the layout Syms follows, see adopt
+	Syms    []int32         // the slot of each name of frame, plus one
 }
 
 func (v *Vars) heapSize() int {
@@ -94,11 +101,39 @@ type Interpreter struct {
 	halt      bool
 	SwitchId  string
 	IgnoreErr bool
-	ErrSource *bytecode.SourceLine
+	Err       *RuntimeError // the last error raised in this scope or the calls it made
 	Id        uint64
 	Processes []*ChildProcess
+	Hosts     map[string]*HostFunc
+	Entry     string // top level node of the last compiled source
+	run       *runState
+	quota     *usage
+	Caps      *Capabilities // nil allows every side effect
+	prec      *Precision    // float settings, shared with the children
+	depth     int           // function calls between this scope and the top level
+	// Stdout, Stderr and Stdin replace the process streams for this
+	// interpreter and its children, nil keeping the process ones
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+	fn     string               // function run by this scope, "" at the top level
+	call   *bytecode.SourceLine // line of the parent that called fn
+	caller *Interpreter         // scope calling this closure, whose parent is where it was defined
+	loaded *moduleCache         // modules imported by the run, shared with the children
+	origin *Interpreter         // scope a pool worker was copied from
 }
 
+// runState is shared by an interpreter and its children during RunContext.
+type runState struct {
+	ctx     context.Context
+	grace   atomic.Int64 // actions left for handling a caught interrupt
+	granted atomic.Bool
+}
+
+// interruptGrace is the number of actions a script may still run after its
+// error block caught an interrupt, enough for a handler but not for a loop.
+const interruptGrace = 1000
+
 type ChildProcess struct {
 	Interp     *Interpreter
 	Done       chan struct{} // signals completion
@@ -169,7 +204,19 @@ func TypeToByte(a any) byte {
 }
 
 func (in *Interpreter) Save(name string, v any) {
-	if old_id, ok := in.V.Names[name]; ok {
+	old_id, ok := in.V.Names[name]
+	in.save(name, old_id, ok, v)
+}
+
+// store saves v into the target of the action.
+func (in *Interpreter) store(action *bytecode.Action, v any) {
+	old_id, ok := in.V.slotAt(action, 0)
+	in.save(action.Target, old_id, ok, v)
+}
+
+// save is Save given the slot of name, if it has one.
+func (in *Interpreter) save(name string, old_id int, ok bool, v any) {
+	if ok {
 		if TypeToByte(v) == in.V.Slots[old_id].Type {
 			// value reassignment
 			switch in.V.Slots[old_id].Type {
@@ -241,7 +288,7 @@ func (in *Interpreter) Save(name string, v any) {
 		in.Nothing(name)
 		return
 	}
-	in.V.Names[name] = len(in.V.Slots)
+	in.V.bind(name, len(in.V.Slots))
 	in.V.Slots = append(in.V.Slots, entry)
 }
 
@@ -327,7 +374,7 @@ func (in *Interpreter) SaveRef(old_id *bytecode.MinPtr, v any) {
 
 func (in *Interpreter) SaveRefNew(v any) *bytecode.MinPtr {
 	entry := Entry{TypeToByte(v), 0}
-	new_ref := &bytecode.MinPtr{0, in.Id}
+	new_ref := &bytecode.MinPtr{Addr: 0, Id: in.Id}
 	switch val := v.(type) {
 	case *big.Int:
 		entry.Index = len(in.V.Ints)
@@ -400,14 +447,14 @@ func (in *Interpreter) GetAny(var_name string) any {
 
 func (in *Interpreter) GetAnyRef(ref *bytecode.MinPtr) any {
 	if in.Id != ref.Id { // id test
-		return in.Parent.GetAnyRef(ref)
+		return in.owner(ref.Id).GetAnyRef(ref)
 	}
 	ind := in.V.Slots[ref.Addr].Index
 	switch in.V.Slots[ref.Addr].Type {
 	case INT:
-		return in.V.Ints[ind]
+		return in.V.intAt(ind)
 	case FLOAT:
-		return in.V.Floats[ind]
+		return in.V.floatAt(ind)
 	case STR:
 		return in.V.Strs[ind]
 	case LIST:
@@ -422,6 +469,10 @@ func (in *Interpreter) GetAnyRef(ref *bytecode.MinPtr) any {
 		return in.V.Funcs[ind]
 	case PAIR:
 		return in.V.Pairs[ind]
+	case SPAN:
+		return in.V.Spans[ind]
+	case ID:
+		return in.V.Ids[ind]
 	case NOTH:
 		return int16(0)
 	}
@@ -435,9 +486,9 @@ func PairKey(in *Interpreter, iname any) string {
 	var iname_str string
 	switch in.V.Slots[key_ref.Addr].Type { //TODO: add all types
 	case INT:
-		iname_str = in.V.Ints[in.V.Slots[key_ref.Addr].Index].String()
+		iname_str = in.V.intAt(in.V.Slots[key_ref.Addr].Index).String()
 	case FLOAT:
-		iname_str = in.V.Floats[in.V.Slots[key_ref.Addr].Index].String()
+		iname_str = in.floatText(in.V.floatAt(in.V.Slots[key_ref.Addr].Index))
 	case BYTE:
 		iname_str = fmt.Sprintf("b.%d", in.V.Bytes[in.V.Slots[key_ref.Addr].Index])
 	case BOOL:
@@ -458,10 +509,19 @@ func PairKey(in *Interpreter, iname any) string {
 }
 
 func NewInterpreter(code, file string) Interpreter {
+	return NewCompiledInterpreter(bytecode.GetCode(code), file)
+}
+
+// NewCompiledInterpreter is NewInterpreter for a unit compiled beforehand,
+// such as one loaded from a .minc file with bytecode.Decode.
+func NewCompiledInterpreter(unit bytecode.Unit, file string) Interpreter {
 	in := Interpreter{}
 	in.Id = rand.Uint64()
-	in.Code = bytecode.GetCode(code)
+	in.Code = unit.Code
+	in.Entry = unit.Entry
 	in.File = &file
+	in.Caps = DefaultCapabilities
+	in.prec = &Precision{}
 	in.V = &Vars{}
 	in.V.Names = make(map[string]int)
 	for _, fn := range bytecode.GenerateFuns() {
@@ -475,8 +535,12 @@ func NewInterpreter(code, file string) Interpreter {
 func NewInterpreterPtr(code, file string) *Interpreter {
 	in := &Interpreter{}
 	in.Id = rand.Uint64()
-	in.Code = bytecode.GetCode(code)
+	unit := bytecode.GetCode(code)
+	in.Code = unit.Code
+	in.Entry = unit.Entry
 	in.File = &file
+	in.Caps = DefaultCapabilities
+	in.prec = &Precision{}
 	in.V = &Vars{}
 	in.V.Names = make(map[string]int)
 	for _, fn := range bytecode.GenerateFuns() {
@@ -493,14 +557,19 @@ func NewInterId(code, file string, parent *Interpreter) *Interpreter {
 	in := Interpreter{}
 	in.Id = inter_id
 	if len(code) > 0 {
-		in.Code = bytecode.GetCode(code)
+		unit := bytecode.GetCode(code)
+		in.Code = unit.Code
+		in.Entry = unit.Entry
 		in.File = &file
+		in.Caps = DefaultCapabilities
+		in.prec = &Precision{}
 	} else if parent != nil {
 		in.Code = parent.Code
 		in.File = parent.File
 		in.Parent = parent
 		in.IgnoreErr = parent.IgnoreErr
-		in.ErrSource = parent.ErrSource
+		in.depth = parent.depth
+		parent.shareRun(&in)
 		in.V.gcCycle = parent.V.gcCycle
 	}
 	in.V = &Vars{}
@@ -514,19 +583,28 @@ func NewInterId(code, file string, parent *Interpreter) *Interpreter {
 	return &in
 }
 
+// local returns name, or temp holding its value when name belongs to an outer
+// scope, for the operators reading the slots of this scope directly.
+func (in *Interpreter) local(name, temp string) string {
+	if _, ok := in.V.Names[name]; ok {
+		return name
+	}
+	in.Save(temp, in.GetAny(name))
+	return temp
+}
+
 func (in *Interpreter) EqualizeTypes(v1, v2 string) (string, string) { // returns tempvar names
+	v1, v2 = in.local(v1, "_temp_l"), in.local(v2, "_temp_r")
 	t1, t2 := in.Type(v1), in.Type(v2)
 	if t1 == t2 {
 		return v1, v2
 	}
 	if t1 == INT && t2 == FLOAT {
-		converted := big.NewFloat(0)
-		converted.SetString(in.V.Ints[in.V.Slots[in.V.Names[v1]].Index].String())
+		converted := in.newFloat().SetInt(in.V.intAt(in.V.Slots[in.V.Names[v1]].Index))
 		in.Save("_temp_a", converted)
 		return "_temp_a", v2
 	} else if t1 == FLOAT && t2 == INT {
-		converted := big.NewFloat(0)
-		converted.SetString(in.V.Ints[in.V.Slots[in.V.Names[v2]].Index].String())
+		converted := in.newFloat().SetInt(in.V.intAt(in.V.Slots[in.V.Names[v2]].Index))
 		in.Save("_temp_a", converted)
 		return v1, "_temp_a"
 	} else if t1 == LIST && t2 == ARR {
@@ -559,7 +637,7 @@ func (in *Interpreter) EqualizeTypes(v1, v2 string) (string, string) { // return
 
 func (in *Interpreter) GetAnySlot(ptr *bytecode.MinPtr) Entry {
 	if in.Id != ptr.Id {
-		return in.Parent.GetAnySlot(ptr)
+		return in.owner(ptr.Id).GetAnySlot(ptr)
 	}
 	return in.V.Slots[ptr.Addr]
 }
@@ -575,6 +653,7 @@ func (in *Interpreter) GC() {
 	newVars := &Vars{
 		Names: make(map[string]int),
 	}
+	newVars.relayout(old)
 
 	// Maps old slot indices to new ones per type
 	intMap := map[int]int{}
@@ -597,16 +676,14 @@ func (in *Interpreter) GC() {
 			if idx, ok := intMap[e.Index]; ok {
 				return idx
 			}
-			newIndex := len(newVars.Ints)
-			newVars.Ints = append(newVars.Ints, old.Ints[e.Index])
+			newIndex := newVars.appendInt(old, e.Index)
 			intMap[e.Index] = newIndex
 			return newIndex
 		case FLOAT:
 			if idx, ok := floatMap[e.Index]; ok {
 				return idx
 			}
-			newIndex := len(newVars.Floats)
-			newVars.Floats = append(newVars.Floats, old.Floats[e.Index])
+			newIndex := newVars.appendFloat(old, e.Index)
 			floatMap[e.Index] = newIndex
 			return newIndex
 		case STR:
@@ -683,7 +760,7 @@ func (in *Interpreter) GC() {
 			for _, slot := range val.Ids {
 				oldEntry := in.GetAnySlot(slot) // old.Slots[slot.Addr]
 				newSlot := copyEntry(oldEntry)
-				newptr := &bytecode.MinPtr{uint64(len(newVars.Slots)), in.Id}
+				newptr := &bytecode.MinPtr{Addr: uint64(len(newVars.Slots)), Id: in.Id}
 				newList.Ids = append(newList.Ids, newptr)
 				newVars.Slots = append(newVars.Slots, Entry{Type: oldEntry.Type, Index: newSlot})
 			}
@@ -698,12 +775,13 @@ func (in *Interpreter) GC() {
 			val := old.Pairs[e.Index]
 			var newPair bytecode.Pair
 			newPair.Ids = make(map[string]*bytecode.MinPtr)
+			newPair.Struct = val.Struct
 			for key, slot := range val.Ids {
 				oldEntry := old.Slots[slot.Addr]
 				newSlot := copyEntry(oldEntry)
 				// old version:
 				//newPair.Ids = append(newPair.Ids, uint64(len(newVars.Slots)))
-				newPair.Ids[key] = &bytecode.MinPtr{uint64(len(newVars.Slots)), in.Id}
+				newPair.Ids[key] = &bytecode.MinPtr{Addr: uint64(len(newVars.Slots)), Id: in.Id}
 				newVars.Slots = append(newVars.Slots, Entry{Type: oldEntry.Type, Index: newSlot})
 			}
 			newIndex := len(newVars.Pairs)
@@ -723,7 +801,7 @@ func (in *Interpreter) GC() {
 		oldEntry := old.Slots[slotIdx]
 		newIndex := copyEntry(oldEntry)
 		newSlotIndex := len(newVars.Slots) // exp
-		newVars.Names[name] = newSlotIndex
+		newVars.bind(name, newSlotIndex)
 		newVars.Slots = append(newVars.Slots, Entry{Type: oldEntry.Type, Index: newIndex})
 		slotMap[slotIdx] = newSlotIndex
 
@@ -733,7 +811,7 @@ func (in *Interpreter) GC() {
 	// Update all entries in newVars.Ids to reflect updated slot mappings
 	for i, oldSlot := range newVars.Ids {
 		if newSlot, ok := slotMap[int(oldSlot.Addr)]; ok {
-			newVars.Ids[i] = &bytecode.MinPtr{uint64(newSlot), in.Id}
+			newVars.Ids[i] = &bytecode.MinPtr{Addr: uint64(newSlot), Id: in.Id}
 		} else {
 			// Clear invalid or collected references
 			newVars.Ids[i] = nil
@@ -764,6 +842,7 @@ func (in *Interpreter) GCE() {
 		Names:  make(map[string]int),
 		gcSize: uint64(heap_size),
 	}
+	newVars.relayout(old)
 
 	// Maps old slot indices to new ones per type
 	intMap := map[int]int{}
@@ -786,16 +865,14 @@ func (in *Interpreter) GCE() {
 			if idx, ok := intMap[e.Index]; ok {
 				return idx
 			}
-			newIndex := len(newVars.Ints)
-			newVars.Ints = append(newVars.Ints, old.Ints[e.Index])
+			newIndex := newVars.appendInt(old, e.Index)
 			intMap[e.Index] = newIndex
 			return newIndex
 		case FLOAT:
 			if idx, ok := floatMap[e.Index]; ok {
 				return idx
 			}
-			newIndex := len(newVars.Floats)
-			newVars.Floats = append(newVars.Floats, old.Floats[e.Index])
+			newIndex := newVars.appendFloat(old, e.Index)
 			floatMap[e.Index] = newIndex
 			return newIndex
 		case STR:
@@ -938,6 +1015,7 @@ func (in *Interpreter) GCE() {
 			val := old.Pairs[e.Index]
 			var newPair bytecode.Pair
 			newPair.Ids = make(map[string]*bytecode.MinPtr)
+			newPair.Struct = val.Struct
 			for key, ptr := range val.Ids {
 				if ptr == nil {
 					newPair.Ids[key] = nil
@@ -972,7 +1050,7 @@ func (in *Interpreter) GCE() {
 		oldEntry := old.Slots[slotIdx]
 		newIndex := copyEntry(oldEntry)
 		newSlotIndex := len(newVars.Slots) // new slot index
-		newVars.Names[name] = newSlotIndex
+		newVars.bind(name, newSlotIndex)
 		newVars.Slots = append(newVars.Slots, Entry{Type: oldEntry.Type, Index: newIndex})
 		slotMap[slotIdx] = newSlotIndex
 	}
@@ -1009,19 +1087,73 @@ func (in *Interpreter) GCE() {
 	newVars.gcCycle = old.gcCycle
 	newVars.gcMax = old.gcMax
 	in.V = newVars
-	runtime.GC()
+	if heap_size >= gcForceSize {
+		runtime.GC()
+	}
 }
 
-func (in *Interpreter) Compile(code, fname string) {
-	in2 := Interpreter{V: &Vars{
-		Names: make(map[string]int),
-	}}
-	in2.Id = rand.Uint64()
-	in2.Code = bytecode.GetCode(code)
-	in2.File = &fname
-	for key := range in2.Code {
-		in.Code[key] = in2.Code[key]
-		delete(in2.Code, key)
+// gcForceSize is the heap size from which a compaction also forces a Go
+// collection, smaller heaps (function calls mostly) are not worth the pause.
+const gcForceSize = 1 << 16
+
+// Compile adds the nodes of code to the interpreter and returns the name of
+// the node running its top level, or the bytecode.SyntaxErrors of code.
+func (in *Interpreter) Compile(code, fname string) (string, error) {
+	unit, err := bytecode.Compile(code)
+	if err != nil {
+		return "", err
+	}
+	return in.Link(unit), nil
+}
+
+// Link adds the nodes of an already compiled unit to the interpreter and
+// returns the name of the node running its top level.
+func (in *Interpreter) Link(unit bytecode.Unit) string {
+	codeMu.Lock()
+	for key := range unit.Code {
+		in.Code[key] = unit.Code[key]
+	}
+	codeMu.Unlock()
+	in.Entry = unit.Entry
+	return unit.Entry
+}
+
+// Bundled holds the files embedded into a bundled executable by path, `!source`
+// takes them from here instead of the disk.
+var Bundled map[string][]byte
+
+// codeMu guards the Code maps, which are shared between an interpreter and
+// all of its children, including pool workers compiling with !run.
+var codeMu sync.RWMutex
+
+// Node returns the actions of a compiled node.
+func (in *Interpreter) Node(name string) ([]bytecode.Action, bool) {
+	codeMu.RLock()
+	defer codeMu.RUnlock()
+	actions, ok := in.Code[name]
+	return actions, ok
+}
+
+// Forget deletes every node compiled together with the given entry node.
+func (in *Interpreter) Forget(entry string) {
+	prefix := entry[:strings.LastIndex(entry, "_")+1]
+	codeMu.Lock()
+	defer codeMu.Unlock()
+	for name := range in.Code {
+		if strings.HasPrefix(name, prefix) {
+			delete(in.Code, name)
+		}
+	}
+}
+
+// SetNode replaces the actions of a node, deleting it when actions is nil.
+func (in *Interpreter) SetNode(name string, actions []bytecode.Action) {
+	codeMu.Lock()
+	defer codeMu.Unlock()
+	if actions == nil {
+		delete(in.Code, name)
+	} else {
+		in.Code[name] = actions
 	}
 }
 
@@ -1034,16 +1166,14 @@ if 1==2:
 else:
     !print "uneq"`
 	in := NewInterpreter(code, "none")
-	in.Run(fmt.Sprintf("_node_%d", bytecode.NodeN-1))
+	in.Run(in.Entry)
 }
 
 func StartFull(code, file string) {
 	in := NewInterpreter(code, file)
-	in.Run(fmt.Sprintf("_node_%d", bytecode.NodeN-1))
+	in.Run(in.Entry)
 }
 
-var error_type, error_message, error_action string
-
 func (in *Interpreter) Error(act bytecode.Action, message, etype string) {
 	// zero_division
 	// index
@@ -1053,14 +1183,7 @@ func (in *Interpreter) Error(act bytecode.Action, message, etype string) {
 	// sys
 	// file
 	// permission
-	if !in.IgnoreErr {
-		// println(message)
-		fmt.Printf("Runtime error: %s\nLocation: line %d\nAction: %s\nType: %s\nLine:\n%s\n", message, act.Source.N+1, act.Type, etype, strings.ReplaceAll(act.Source.Source, "\r\n", "\n"))
-	}
-	in.ErrSource = act.Source
-	error_type = etype
-	error_message = message
-	error_action = act.Type
+	in.Raise(act, &RuntimeError{Type: etype, Message: message})
 }
 
 func (in *Interpreter) CheckArgN(action bytecode.Action, minimal, maximal int) bool {
@@ -1089,16 +1212,16 @@ func (in *Interpreter) GetSlot(name string) Entry {
 }
 
 func (in *Interpreter) CheckDtype(action bytecode.Action, index int, dtypes ...byte) bool {
-	dstrings := map[byte]string{0: "noth", 1: "int", 2: "float", 3: "str", 4: "arr", 5: "list", 6: "pair", 7: "bool", 8: "byte", 9: "func", 10: "id", SPAN: "span"}
 	found := false
+	got := in.typeAt(&action, 1+index)
 	for _, dtype := range dtypes {
-		// in.V.Slots[in.V.Names[string(action.Variables[index])]].Type
-		if in.Type(string(action.Variables[index])) == dtype {
+		if got == dtype {
 			found = true
 			break
 		}
 	}
 	if !found {
+		dstrings := map[byte]string{0: "noth", 1: "int", 2: "float", 3: "str", 4: "arr", 5: "list", 6: "pair", 7: "bool", 8: "byte", 9: "func", 10: "id", SPAN: "span"}
 		l := bytecode.List{}
 		for _, dtype := range dtypes {
 			ListAppend(&l, in, dstrings[dtype])
@@ -1148,7 +1271,7 @@ func (in *Interpreter) GetRef(v any) *bytecode.MinPtr {
 	}
 	value := len(in.V.Slots)
 	in.V.Slots = append(in.V.Slots, entry)
-	return &bytecode.MinPtr{uint64(value), in.Id}
+	return &bytecode.MinPtr{Addr: uint64(value), Id: in.Id}
 }
 
 func ListAppend(l *bytecode.List, in *Interpreter, item any) {
@@ -1166,9 +1289,9 @@ func ListString(l *bytecode.List, in *Interpreter) string {
 		item := interp.V.Slots[item_id.Addr].Index
 		switch in.TypeRef(item_id) {
 		case INT:
-			elements = append(elements, interp.V.Ints[item].String())
+			elements = append(elements, interp.V.intAt(item).String())
 		case FLOAT:
-			elements = append(elements, interp.V.Floats[item].String())
+			elements = append(elements, in.floatText(interp.V.floatAt(item)))
 		case BOOL:
 			elements = append(elements, ternary(interp.V.Bools[item], "true", "false"))
 		case BYTE:
@@ -1191,6 +1314,9 @@ func ListString(l *bytecode.List, in *Interpreter) string {
 }
 
 func PairString(p *bytecode.Pair, in *Interpreter) string {
+	if p.Struct != nil {
+		return StructString(p, in)
+	}
 	elements := []string{}
 	for key, item := range p.Ids {
 		i := 0
@@ -1203,9 +1329,9 @@ func PairString(p *bytecode.Pair, in *Interpreter) string {
 		}
 		switch in.V.Slots[item.Addr].Type {
 		case INT:
-			elements = append(elements, dkey+": "+in.V.Ints[in.V.Slots[item.Addr].Index].String())
+			elements = append(elements, dkey+": "+in.V.intAt(in.V.Slots[item.Addr].Index).String())
 		case FLOAT:
-			elements = append(elements, dkey+": "+in.V.Floats[in.V.Slots[item.Addr].Index].String())
+			elements = append(elements, dkey+": "+in.floatText(in.V.floatAt(in.V.Slots[item.Addr].Index)))
 		case BOOL:
 			elements = append(elements, dkey+": "+ternary(in.V.Bools[in.V.Slots[item.Addr].Index], "true", "false"))
 		case BYTE:
@@ -1229,7 +1355,7 @@ func PairString(p *bytecode.Pair, in *Interpreter) string {
 // return in.V.Ints[in.V.Slots[in.V.Names[vname]].Index]
 func (in *Interpreter) NamedInt(vname string) *big.Int {
 	if slot_index, ok := in.V.Names[vname]; ok {
-		return in.V.Ints[in.V.Slots[slot_index].Index]
+		return in.V.intAt(in.V.Slots[slot_index].Index)
 	} else {
 		if in.Parent != nil {
 			return in.Parent.NamedInt(vname)
@@ -1240,7 +1366,7 @@ func (in *Interpreter) NamedInt(vname string) *big.Int {
 }
 func (in *Interpreter) NamedFloat(vname string) *big.Float {
 	if slot_index, ok := in.V.Names[vname]; ok {
-		return in.V.Floats[in.V.Slots[slot_index].Index]
+		return in.V.floatAt(in.V.Slots[slot_index].Index)
 	} else {
 		if in.Parent != nil {
 			return in.Parent.NamedFloat(vname)
@@ -1362,7 +1488,7 @@ func PairAppend(p *bytecode.Pair, in *Interpreter, item any, iname any) {
 func (in *Interpreter) RemoveName(name string) {
 	_, ok := in.V.Names[name]
 	if ok {
-		delete(in.V.Names, name)
+		in.V.unbind(name)
 	}
 }
 
@@ -1470,7 +1596,7 @@ func (in *Interpreter) NewSpan(length int, dtype byte) bytecode.Span {
 			s.Start = uint64(len(in.V.Pairs))
 			s.Length = uint64(length)
 			for range length {
-				p := bytecode.Pair{make(map[string]*bytecode.MinPtr)}
+				p := bytecode.Pair{Ids: make(map[string]*bytecode.MinPtr)}
 				in.V.Pairs = append(in.V.Pairs, p)
 			}
 		case NOTH:
@@ -1554,7 +1680,7 @@ func (in *Interpreter) StringSpan(l bytecode.Span) string {
 		case INT:
 			elements = append(elements, in.V.Ints[item].String())
 		case FLOAT:
-			elements = append(elements, in.V.Floats[item].String())
+			elements = append(elements, in.floatText(in.V.Floats[item]))
 		case BOOL:
 			elements = append(elements, ternary(in.V.Bools[item], "true", "false"))
 		case BYTE:
@@ -1594,7 +1720,7 @@ func (in *Interpreter) StringifyAny(original any, prefix, suffix int) string {
 			str += "0"
 		}
 	case *big.Float:
-		str = value.String()
+		str = in.floatText(value)
 		is_neg := false
 		if strings.HasPrefix(str, "-") {
 			is_neg = true
@@ -1799,70 +1925,11 @@ func (in *Interpreter) SortListStep(l *bytecode.List) error {
 	return nil
 }
 
-func (in *Interpreter) Parse(str string) []string {
-	reg_var := regexp.MustCompile(`\{.+?\}`)
-	for _, match := range reg_var.FindAllString(str, -1) {
-		code := match[1 : len(match)-1]
-		if variable, ok := in.V.Names[code]; ok {
-			a := in.GetAnyRef(&bytecode.MinPtr{uint64(variable), in.Id})
-			text := ""
-			switch v := a.(type) {
-			case string:
-				text = v
-			case *big.Int:
-				text = v.String()
-			case *big.Float:
-				text = v.String()
-			case byte:
-				text = fmt.Sprintf("%d", v)
-			case bool:
-				text = ternary(v, "true", "false")
-			case bytecode.Function:
-				text = "func." + v.Name
-			case bytecode.List:
-				text = ListString(&v, in)
-			case bytecode.Array:
-				text = v.String()
-			case bytecode.Pair:
-				text = PairString(&v, in)
-			}
-			str = strings.ReplaceAll(str, match, text)
-		} else {
-			// TODO: ###
-			in_p := NewInterpreter(code, ".")
-			for name := range in.V.Names {
-				in_p.Save(name, in.GetAny(name))
-			}
-			node_name := fmt.Sprintf("_node_%d", bytecode.NodeN-1)
-			in_p.Code[node_name] = in_p.Code[node_name][:len(in_p.Code[node_name])-1]
-			in_p.Run(node_name)
-			a := in_p.GetAny(in_p.Code[node_name][len(in_p.Code[node_name])-1].Target)
-			text := ""
-			switch v := a.(type) {
-			case string:
-				text = v
-			case *big.Int:
-				text = v.String()
-			case *big.Float:
-				text = v.String()
-			case byte:
-				text = fmt.Sprintf("%d", v)
-			case bool:
-				text = ternary(v, "true", "false")
-			case bytecode.Function:
-				text = "func." + v.Name
-			case bytecode.List:
-				text = ListString(&v, in)
-			case bytecode.Array:
-				text = v.String()
-			case bytecode.Pair:
-				text = PairString(&v, in)
-			}
-			str = strings.ReplaceAll(str, match, text)
-			in_p.Destroy()
-		}
-	}
-	return GetParts(str)
+// Parse splits the shell command str, its {...} interpolated as by Fmt, into
+// its arguments.
+func (in *Interpreter) Parse(action bytecode.Action, str string) ([]string, bool) {
+	str, failed := in.Fmt(action, str)
+	return GetParts(str), failed
 }
 
 func GetParts(text string) []string {
@@ -1926,70 +1993,69 @@ func GetParts(text string) []string {
 	return parts
 }
 
-func (in *Interpreter) Fmt(str string) string {
+// Fmt replaces each {...} of str, a variable or an expression, with the
+// text of its value, which for an instance is the one print shows.
+func (in *Interpreter) Fmt(action bytecode.Action, str string) (string, bool) {
 	reg_var := regexp.MustCompile(`\{.+?\}`)
 	for _, match := range reg_var.FindAllString(str, -1) {
 		code := match[1 : len(match)-1]
 		if variable, ok := in.V.Names[code]; ok {
-			a := in.GetAnyRef(&bytecode.MinPtr{uint64(variable), in.Id})
-			text := ""
-			switch v := a.(type) {
-			case string:
-				text = v
-			case *big.Int:
-				text = v.String()
-			case *big.Float:
-				text = v.String()
-			case byte:
-				text = fmt.Sprintf("%d", v)
-			case bool:
-				text = ternary(v, "true", "false")
-			case *bytecode.Function:
-				text = "func." + v.Name
-			case bytecode.List:
-				text = ListString(&v, in)
-			case bytecode.Array:
-				text = v.String()
-			case bytecode.Pair:
-				text = PairString(&v, in)
+			a := in.GetAnyRef(&bytecode.MinPtr{Addr: uint64(variable), Id: in.Id})
+			text, failed := in.interpolated(action, a)
+			if failed {
+				return "", true
 			}
 			str = strings.ReplaceAll(str, match, text)
 		} else {
 			// TODO: ###
 			in_p := NewInterpreter(code, ".")
+			in.shareRun(&in_p)
 			for name := range in.V.Names {
 				in_p.Save(name, in.GetAny(name))
 			}
-			node_name := fmt.Sprintf("_node_%d", bytecode.NodeN-1)
+			node_name := in_p.Entry
 			in_p.Code[node_name] = in_p.Code[node_name][:len(in_p.Code[node_name])-1]
 			in_p.Run(node_name)
 			a := in_p.GetAny(in_p.Code[node_name][len(in_p.Code[node_name])-1].Target)
-			text := ""
-			switch v := a.(type) {
-			case string:
-				text = v
-			case *big.Int:
-				text = v.String()
-			case *big.Float:
-				text = v.String()
-			case byte:
-				text = fmt.Sprintf("%d", v)
-			case bool:
-				text = ternary(v, "true", "false")
-			case *bytecode.Function:
-				text = "func." + v.Name
-			case bytecode.List:
-				text = ListString(&v, in)
-			case bytecode.Array:
-				text = v.String()
-			case bytecode.Pair:
-				text = PairString(&v, in)
+			text, failed := in.interpolated(action, a)
+			if failed {
+				return "", true
 			}
 			str = strings.ReplaceAll(str, match, text)
 			in_p.Destroy()
 		}
 	}
-	return str
+	return str, false
+}
+
+// interpolated is the text Fmt puts in place of the value a, the one show
+// gives for an instance or a list or pair holding one.
+func (in *Interpreter) interpolated(action bytecode.Action, a any) (string, bool) {
+	if in.holdsInstance(a) {
+		return in.show(action, a)
+	}
+	text := ""
+	switch v := a.(type) {
+	case string:
+		text = v
+	case *big.Int:
+		text = v.String()
+	case *big.Float:
+		text = in.floatText(v)
+	case byte:
+		text = fmt.Sprintf("%d", v)
+	case bool:
+		text = ternary(v, "true", "false")
+	case *bytecode.Function:
+		text = "func." + v.Name
+	case bytecode.List:
+		text = ListString(&v, in)
+	case bytecode.Array:
+		text = v.String()
+	case bytecode.Pair:
+		text = PairString(&v, in)
+	}
+	return text, false
 }
 
 func (in *Interpreter) Stringify(v any) string {
@@ -1998,7 +2064,7 @@ func (in *Interpreter) Stringify(v any) string {
 	case *big.Int:
 		str = vt.String()
 	case *big.Float:
-		str = vt.String()
+		str = in.floatText(vt)
 	case string:
 		str = vt
 	case byte:
@@ -2020,15 +2086,15 @@ func (in *Interpreter) Stringify(v any) string {
 }
 
 var RL = input.Rl
-var protected_actions = []string{"for", "const", "pool", "error", "func", "process"}
+var protected_actions = []string{"for", "const", "pool", "error", "func", "struct", "process", "##", "<lambda>"}
 
 func (in *Interpreter) RunSort(ftype string, sl *bytecode.SourceLine) bool {
 	node_name := fmt.Sprintf("_runner_%x", rand.Int64())
 	target := fmt.Sprintf("_targ_%x", rand.Int64())
 	arguments := []bytecode.Variable{("item")}
-	in.Code[node_name] = []bytecode.Action{{Target: target, Type: ftype, Variables: arguments, Source: sl}}
+	in.SetNode(node_name, []bytecode.Action{{Target: target, Type: ftype, Variables: arguments, Source: sl}})
 	boolean := in.Run(node_name)
-	delete(in.Code, node_name)
+	in.SetNode(node_name, nil)
 	return boolean
 }
 
@@ -2071,9 +2137,77 @@ func (in *Interpreter) integrateChildResult(proc *ChildProcess) {
 	in.Save(proc.TargetList, lst)
 }
 
+// RunContext is Run stopping with an "interrupt" error once ctx is cancelled,
+// or with a "timeout" error once its deadline passes. Cancellation is checked
+// before every action, so loop iterations and nested calls stop as well. The
+// first interrupt caught by an error block leaves the script interruptGrace
+// more actions to handle it, later ones stop it right away.
+func (in *Interpreter) RunContext(ctx context.Context, node_name string) bool {
+	prev := in.run
+	in.run = &runState{ctx: ctx}
+	defer func() { in.run = prev }()
+	return in.Run(node_name)
+}
+
+func (in *Interpreter) interrupted(action bytecode.Action) bool {
+	select {
+	case <-in.run.ctx.Done():
+	default:
+		return false
+	}
+	if in.run.grace.Add(-1) >= 0 {
+		return false
+	}
+	if errors.Is(in.run.ctx.Err(), context.DeadlineExceeded) {
+		in.Error(action, "execution timed out", "timeout")
+	} else {
+		in.Error(action, "execution interrupted", "interrupt")
+	}
+	return true
+}
+
+// sleep waits for d, returning early when the run is cancelled.
+func (in *Interpreter) sleep(d time.Duration) {
+	if in.run == nil {
+		time.Sleep(d)
+		return
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-in.run.ctx.Done():
+	}
+}
+
 // MAIN FUNCTION START
 func (in *Interpreter) Run(node_name string) bool {
-	actions, ok := in.Code[node_name]
+	var deferred []string
+	failed := in.runNode(node_name, &deferred)
+	if deferred != nil {
+		failed = in.runDeferred(deferred, failed)
+	}
+	return failed
+}
+
+// runDeferred runs the defer blocks registered by a finished block, the last
+// one first. They run after a return or an error as well, the first error
+// staying the reported one.
+func (in *Interpreter) runDeferred(nodes []string, failed bool) bool {
+	halted, caught := in.halt, in.Err
+	for n := len(nodes) - 1; n >= 0; n-- {
+		in.halt = false
+		if in.Run(nodes[n]) && !failed {
+			failed, caught = true, in.Err
+		}
+		halted = halted || in.halt
+	}
+	in.halt, in.Err = halted, caught
+	return failed
+}
+
+func (in *Interpreter) runNode(node_name string, deferred *[]string) bool {
+	actions, ok := in.Node(node_name)
 	if !ok && strings.HasPrefix(node_name, "action") {
 		a := bytecode.Action{}
 		a.Parse(node_name)
@@ -2081,61 +2215,63 @@ func (in *Interpreter) Run(node_name string) bool {
 	}
 	focus := 0
 	for focus < len(actions) && !in.halt {
+		if in.run != nil && actions[focus].Source != nil && in.interrupted(actions[focus]) {
+			return true
+		}
+		if in.quota != nil && actions[focus].Source != nil && in.overQuota(actions[focus]) {
+			return true
+		}
 		in.checkChildProcesses()
 		action := actions[focus]
-		for _, vv := range action.Variables {
-			if _, ok := in.V.Names[string(vv)]; !ok && !bytecode.Has(protected_actions, action.Type) {
-				if in.Parent == nil {
-					in.Error(action, "Undeclared variable: "+string(vv), "undeclared")
-					return true
-				}
-				interp := in.Parent
-				_, ok = interp.V.Names[string(vv)]
-				for !ok && interp.Parent != nil {
-					interp = interp.Parent
-					_, ok = interp.V.Names[string(vv)]
-				}
-				if !ok {
-					in.Error(action, "Undeclared variable: "+string(vv), "undeclared")
-					return true
-				}
+		if in.V.frame == nil && action.Frame != nil {
+			in.V.adopt(action.Frame)
+		}
+		for m, vv := range action.Variables {
+			if !in.declared(&action, m) && !bytecode.Has(protected_actions, action.Type) {
+				in.Error(action, "Undeclared variable: "+string(vv), "undeclared")
+				return true
 			}
 		}
-		if action.Type != "++" && action.Type != "--" {
-			in.Nothing(action.Target)
+		if in.fastAction(&action) {
+			focus++
+			continue
+		}
+		if action.Type != "++" && action.Type != "--" && action.Type != "&=" {
+			slot_id, ok := in.V.slotAt(&action, 0)
+			in.nothing(action.Target, slot_id, ok)
 		} // TODO: check if creates bloat
 		switch action.Type {
 		case "const":
-			reg_int := regexp.MustCompile(`^-?[0-9]+$`)
-			reg_float := regexp.MustCompile(`^-?[0-9]+\.[0-9]+$`)
-			reg_byte := regexp.MustCompile(`^-?b\.[0-9]+$`)
-			switch {
-			case reg_float.MatchString(string(actions[focus].Variables[0])):
-				//f64, _ := strconv.ParseFloat(string(actions[focus].Variables[0]), 64)
-				b := big.NewFloat(0)
-				b.SetString(string(actions[focus].Variables[0]))
-				in.Save(actions[focus].Target, b)
-			case reg_byte.MatchString(string(actions[focus].Variables[0])):
-				i64, _ := strconv.ParseInt(string(actions[focus].Variables[0])[2:], 10, 64)
-				in.Save(actions[focus].Target, byte(i64))
-			case reg_int.MatchString(string(actions[focus].Variables[0])):
-				b := big.NewInt(0)
-				b.SetString(string(actions[focus].Variables[0]), 10)
-				in.Save(actions[focus].Target, b)
-			case string(actions[focus].Variables[0]) == "true" || string(actions[focus].Variables[0]) == "false":
-				in.Save(action.Target, string(actions[focus].Variables[0]) == "true")
+			value := action.Value
+			if value == nil {
+				value = bytecode.Constant(action.Variables[0])
+			}
+			switch c := value.(type) {
+			case *big.Int:
+				in.Save(action.Target, new(big.Int).Set(c))
+			case *big.Float:
+				if in.customPrecision() {
+					c, _ = in.parseFloat(action.First())
+				}
+				in.Save(action.Target, new(big.Float).Copy(c))
 			default:
-				in.Save(actions[focus].Target, string(actions[focus].Variables[0])[1:len(string(actions[focus].Variables[0]))-1])
+				in.Save(action.Target, c)
 			}
 		case "+":
+			if sum, ok, err := in.protocol(action, in.GetAny(string(action.Variables[0])), "_add", "", in.GetAny(string(action.Variables[1]))); ok {
+				if err {
+					return true
+				}
+				in.Save(action.Target, sum)
+				break
+			}
 			o, t := in.EqualizeTypes(string(actions[focus].Variables[0]), string(actions[focus].Variables[1]))
 			switch in.V.Slots[in.V.Names[o]].Type {
 			case INT:
 				in.Save(actions[focus].Target, big.NewInt(0))
-				in.V.Ints[in.V.Slots[in.V.Names[actions[focus].Target]].Index].Add(in.V.Ints[in.V.Slots[in.V.Names[o]].Index], in.V.Ints[in.V.Slots[in.V.Names[t]].Index])
+				in.V.intAt(in.V.Slots[in.V.Names[actions[focus].Target]].Index).Add(in.V.intAt(in.V.Slots[in.V.Names[o]].Index), in.V.intAt(in.V.Slots[in.V.Names[t]].Index))
 			case FLOAT:
-				in.Save(actions[focus].Target, big.NewFloat(0))
-				in.V.Floats[in.V.Slots[in.V.Names[actions[focus].Target]].Index].Add(in.V.Floats[in.V.Slots[in.V.Names[o]].Index], in.V.Floats[in.V.Slots[in.V.Names[t]].Index])
+				in.Save(actions[focus].Target, in.floatOp("+", in.NamedFloat(o), in.NamedFloat(t)))
 			case STR:
 				err := in.CheckDtype(action, 1, STR)
 				if err {
@@ -2187,6 +2323,10 @@ func (in *Interpreter) Run(node_name string) bool {
 			case PAIR:
 				p := in.NamedPair(string(action.Variables[0]))
 				ind := PairKey(in, in.GetAny(string(action.Variables[1])))
+				if method := in.method(p, action); method != nil {
+					in.Save(action.Target, method)
+					break
+				}
 				if _, ok := p.Ids[ind]; !ok {
 					in.Error(action, fmt.Sprintf("invalid pairing key: %s", strings.SplitN(ind, ":", 2)[1]), "index")
 					return true
@@ -2233,7 +2373,7 @@ func (in *Interpreter) Run(node_name string) bool {
 				}
 				err := in.DeepAssign(&l, in.GetAny(action.Second()), inds)
 				if err != nil {
-					in.Error(action, err.Error(), "index")
+					in.Error(action, err.Error(), assignError(err))
 					return true
 				}
 				in.Save(action.Target, l)
@@ -2245,7 +2385,7 @@ func (in *Interpreter) Run(node_name string) bool {
 				}
 				err := in.DeepAssign(&l, in.GetAny(action.Second()), inds)
 				if err != nil {
-					in.Error(action, err.Error(), "index")
+					in.Error(action, err.Error(), assignError(err))
 					return true
 				}
 				in.Save(action.Target, l)
@@ -2262,10 +2402,9 @@ func (in *Interpreter) Run(node_name string) bool {
 			switch in.V.Slots[in.V.Names[o]].Type {
 			case INT:
 				in.Save(actions[focus].Target, big.NewInt(0))
-				in.V.Ints[in.V.Slots[in.V.Names[actions[focus].Target]].Index].Sub(in.V.Ints[in.V.Slots[in.V.Names[o]].Index], in.V.Ints[in.V.Slots[in.V.Names[t]].Index])
+				in.V.intAt(in.V.Slots[in.V.Names[actions[focus].Target]].Index).Sub(in.V.intAt(in.V.Slots[in.V.Names[o]].Index), in.V.intAt(in.V.Slots[in.V.Names[t]].Index))
 			case FLOAT:
-				in.Save(actions[focus].Target, big.NewFloat(0))
-				in.V.Floats[in.V.Slots[in.V.Names[actions[focus].Target]].Index].Sub(in.V.Floats[in.V.Slots[in.V.Names[o]].Index], in.V.Floats[in.V.Slots[in.V.Names[t]].Index])
+				in.Save(actions[focus].Target, in.floatOp("-", in.NamedFloat(o), in.NamedFloat(t)))
 			case BYTE:
 				in.Save(actions[focus].Target, in.NamedByte(o)-in.NamedByte(t))
 			}
@@ -2274,10 +2413,9 @@ func (in *Interpreter) Run(node_name string) bool {
 			switch in.V.Slots[in.V.Names[o]].Type {
 			case INT:
 				in.Save(actions[focus].Target, big.NewInt(0))
-				in.V.Ints[in.V.Slots[in.V.Names[actions[focus].Target]].Index].Mul(in.V.Ints[in.V.Slots[in.V.Names[o]].Index], in.V.Ints[in.V.Slots[in.V.Names[t]].Index])
+				in.V.intAt(in.V.Slots[in.V.Names[actions[focus].Target]].Index).Mul(in.V.intAt(in.V.Slots[in.V.Names[o]].Index), in.V.intAt(in.V.Slots[in.V.Names[t]].Index))
 			case FLOAT:
-				in.Save(actions[focus].Target, big.NewFloat(0))
-				in.V.Floats[in.V.Slots[in.V.Names[actions[focus].Target]].Index].Mul(in.V.Floats[in.V.Slots[in.V.Names[o]].Index], in.V.Floats[in.V.Slots[in.V.Names[t]].Index])
+				in.Save(actions[focus].Target, in.floatOp("*", in.NamedFloat(o), in.NamedFloat(t)))
 			case BYTE:
 				in.Save(actions[focus].Target, in.NamedByte(o)*in.NamedByte(t))
 			}
@@ -2285,14 +2423,11 @@ func (in *Interpreter) Run(node_name string) bool {
 			o, t := in.EqualizeTypes(string(actions[focus].Variables[0]), string(actions[focus].Variables[1]))
 			switch in.V.Slots[in.V.Names[o]].Type {
 			case INT:
-				f := big.NewFloat(0)
-				f.SetInt(in.NamedInt(o))
-				f2 := big.NewFloat(0)
-				f2.SetInt(in.NamedInt(t))
-				in.Save(action.Target, f.Quo(f, f2))
+				f := in.newFloat().SetInt(in.NamedInt(o))
+				f2 := in.newFloat().SetInt(in.NamedInt(t))
+				in.Save(action.Target, in.floatOp("/", f, f2))
 			case FLOAT:
-				in.Save(actions[focus].Target, big.NewFloat(0))
-				in.V.Floats[in.V.Slots[in.V.Names[actions[focus].Target]].Index].Quo(in.V.Floats[in.V.Slots[in.V.Names[o]].Index], in.V.Floats[in.V.Slots[in.V.Names[t]].Index])
+				in.Save(actions[focus].Target, in.floatOp("/", in.NamedFloat(o), in.NamedFloat(t)))
 			case BYTE:
 				// TODO: make it work according to the spec
 				in.Save(actions[focus].Target, in.NamedByte(o)/in.NamedByte(t))
@@ -2308,9 +2443,7 @@ func (in *Interpreter) Run(node_name string) bool {
 				in.Save(action.Target, big.NewInt(0))
 				in.NamedInt(action.Target).Div(in.NamedInt(o), in.NamedInt(t))
 			case FLOAT:
-				f := in.NamedFloat(o)
-				f2 := in.NamedFloat(t)
-				result := f.Quo(f, f2)
+				result := in.floatOp("/", in.NamedFloat(o), in.NamedFloat(t))
 				rounded, _ := result.Int(big.NewInt(0))
 				in.Save(action.Target, rounded)
 			case BYTE:
@@ -2322,11 +2455,11 @@ func (in *Interpreter) Run(node_name string) bool {
 			switch in.V.Slots[in.V.Names[o]].Type {
 			case INT:
 				in.Save(actions[focus].Target, big.NewInt(0))
-				in.V.Ints[in.V.Slots[in.V.Names[actions[focus].Target]].Index].DivMod(in.V.Ints[in.V.Slots[in.V.Names[o]].Index], in.V.Ints[in.V.Slots[in.V.Names[t]].Index], in.V.Ints[in.V.Slots[in.V.Names[actions[focus].Target]].Index])
+				in.V.intAt(in.V.Slots[in.V.Names[actions[focus].Target]].Index).DivMod(in.V.intAt(in.V.Slots[in.V.Names[o]].Index), in.V.intAt(in.V.Slots[in.V.Names[t]].Index), in.V.intAt(in.V.Slots[in.V.Names[actions[focus].Target]].Index))
 			case FLOAT:
 				// TODO: make it work according to the spec
 				in.Save(actions[focus].Target, big.NewFloat(0))
-				in.V.Floats[in.V.Slots[in.V.Names[actions[focus].Target]].Index].Sub(in.V.Floats[in.V.Slots[in.V.Names[o]].Index], in.V.Floats[in.V.Slots[in.V.Names[t]].Index])
+				in.V.floatAt(in.V.Slots[in.V.Names[actions[focus].Target]].Index).Sub(in.V.floatAt(in.V.Slots[in.V.Names[o]].Index), in.V.floatAt(in.V.Slots[in.V.Names[t]].Index))
 			case BYTE:
 				in.Save(actions[focus].Target, in.NamedByte(o)-in.NamedByte(t))
 			}
@@ -2337,18 +2470,32 @@ func (in *Interpreter) Run(node_name string) bool {
 				in.Save(action.Target, in.NamedInt(one))
 				in.Save(action.Target, PowInt(in.NamedInt(action.Target), in.NamedInt(two)))
 			case FLOAT:
-				o, _ := in.NamedFloat(one).Float64()
-				t, _ := in.NamedFloat(two).Float64()
-				in.Save(action.Target, big.NewFloat(math.Pow(o, t)))
+				in.Save(action.Target, in.floatPow(in.NamedFloat(one), in.NamedFloat(two)))
 			case BYTE:
 				// TODO:
 				//in.V.Bytes[in.V.Names[action.Target]] = byte(math.Pow(float64(in.V.Bytes[in.V.Names[string(action.Variables[0])]]), float64(in.V.Bytes[in.V.Names[string(action.Variables[1])]])))
 				//in.V.Types[in.V.Names[action.Target]] = BYTE
 			}
 		case "func":
-			name := string(actions[focus].Variables[0])
-			fn := &bytecode.Function{name, actions[focus].Target, actions[focus].Variables[1:], actions[focus].Target}
-			in.Save(name, fn)
+			fn := in.declare(actions, focus)
+			in.Save(fn.Name, fn)
+		case "struct":
+			fn := in.declareStruct(actions, focus)
+			in.Save(fn.Name, fn)
+		case "<lambda>":
+			params := bytecode.ParseParams(action.Variables[1:])
+			fn := &bytecode.Function{Name: action.Type, Vars: bytecode.ParamNames(params), Params: params, Node: action.First()}
+			if in.Parent != nil {
+				fn.Env = in
+			}
+			in.Save(action.Target, fn)
+		case "<import>":
+			m, failed := in.importModule(action, in.NamedStr(action.First()))
+			if failed {
+				return true
+			}
+			in.Save(action.Target, in.exports(m))
+		case "##":
 		case "return":
 			if len(action.Variables) == 1 {
 				in.Save("_return_", in.GetAny(string(action.Variables[0])))
@@ -2594,23 +2741,26 @@ func (in *Interpreter) Run(node_name string) bool {
 			for _, interp := range interpreters {
 				interp.Destroy()
 			}
-		case "++":
-			slot := in.GetSlot(string(action.Variables[0]))
-			switch slot.Type {
-			case INT:
-				i := big.NewInt(0)
-				i.Set(in.V.Ints[slot.Index])
-				in.Save(actions[focus].Target, i)
-				in.V.Ints[in.V.Slots[in.V.Names[action.Target]].Index].Add(in.V.Ints[slot.Index], big.NewInt(1))
-			}
-		case "--":
-			slot := in.GetSlot(string(action.Variables[0]))
-			switch slot.Type {
-			case INT:
-				i := big.NewInt(0)
-				i.Set(in.V.Ints[slot.Index])
-				in.Save(actions[focus].Target, i)
-				in.V.Ints[in.V.Slots[in.V.Names[action.Target]].Index].Sub(in.V.Ints[slot.Index], big.NewInt(1))
+		case "++", "--":
+			// the variable may belong to an enclosing scope, written back like &=
+			interp := in
+			_, ok := interp.V.Names[action.Target]
+			for !ok {
+				interp = interp.Parent
+				if interp == nil {
+					in.Error(action, "undeclared variable name", "undeclared")
+					return true
+				}
+				_, ok = interp.V.Names[action.Target]
+			}
+			if interp.Type(action.Target) == INT {
+				i := new(big.Int).Set(interp.NamedInt(action.Target))
+				if action.Type == "++" {
+					i.Add(i, big.NewInt(1))
+				} else {
+					i.Sub(i, big.NewInt(1))
+				}
+				interp.Save(action.Target, i)
 			}
 		case "=":
 			in.Save(action.Target, in.GetAny(string(actions[focus].Variables[0])))
@@ -2670,12 +2820,16 @@ func (in *Interpreter) Run(node_name string) bool {
 					return true
 				}
 			}
-		case "==":
-			o, t := in.EqualizeTypes(string(actions[focus].Variables[0]), string(actions[focus].Variables[1]))
-			in.Save(action.Target, in.CompareName(o, t))
-		case "!=":
+		case "==", "!=":
+			if eq, ok, err := in.protocol(action, in.GetAny(string(action.Variables[0])), "_eq", "bool", in.GetAny(string(action.Variables[1]))); ok {
+				if err {
+					return true
+				}
+				in.Save(action.Target, eq.(bool) == (action.Type == "=="))
+				break
+			}
 			o, t := in.EqualizeTypes(string(actions[focus].Variables[0]), string(actions[focus].Variables[1]))
-			in.Save(action.Target, !in.CompareName(o, t))
+			in.Save(action.Target, in.CompareName(o, t) == (action.Type == "=="))
 		case "<":
 			o, t := in.EqualizeTypes(string(actions[focus].Variables[0]), string(actions[focus].Variables[1]))
 			switch in.V.Slots[in.V.Names[o]].Type {
@@ -2720,6 +2874,12 @@ func (in *Interpreter) Run(node_name string) bool {
 				return true
 			}
 			in.Save(action.Target, in.NamedBool(string(action.Variables[0])) || in.NamedBool(string(action.Variables[1])))
+		case "not":
+			err := in.CheckDtype(action, 0, BOOL)
+			if err {
+				return true
+			}
+			in.Save(action.Target, !in.NamedBool(string(action.Variables[0])))
 		case "if":
 			err := in.CheckDtype(actions[focus], 0, BOOL)
 			if err {
@@ -2848,6 +3008,15 @@ func (in *Interpreter) Run(node_name string) bool {
 					cname := "_for" + action.Target + "_" + spanName
 					sources = append(sources, cname)
 					in.Save(cname, copied)
+				} else if items, ok, err := in.protocol(action, in.GetAny(spanName), "_iter", "list"); ok {
+					// a user collection is looped over the list its _iter returns
+					if err {
+						return true
+					}
+					loopLen = uint64(len(items.(bytecode.List).Ids))
+					cname := "_for" + action.Target + "_" + spanName
+					sources = append(sources, cname)
+					in.Save(cname, items)
 				}
 				targets = append(targets, targetName)
 			}
@@ -2891,9 +3060,9 @@ func (in *Interpreter) Run(node_name string) bool {
 						}
 						switch interp.V.Slots[valIndex.Addr].Type {
 						case INT:
-							in.Save(targets[i], interp.V.Ints[interp.V.Slots[valIndex.Addr].Index])
+							in.Save(targets[i], interp.V.intAt(interp.V.Slots[valIndex.Addr].Index))
 						case FLOAT:
-							in.Save(targets[i], interp.V.Floats[interp.V.Slots[valIndex.Addr].Index])
+							in.Save(targets[i], interp.V.floatAt(interp.V.Slots[valIndex.Addr].Index))
 						case STR:
 							in.Save(targets[i], interp.V.Strs[interp.V.Slots[valIndex.Addr].Index])
 						case BOOL:
@@ -2930,36 +3099,72 @@ func (in *Interpreter) Run(node_name string) bool {
 				frozen = append(frozen, string(v))
 			}
 			in.SpawnProcess(node, action.Second(), action.First(), frozen)
+		case "defer":
+			*deferred = append(*deferred, action.Target)
 		case "error":
-			e := in.CheckArgN(action, 0, 2)
-			if e {
-				return e
+			names := []bytecode.Variable{}
+			for _, v := range action.Variables {
+				if !strings.HasPrefix(string(v), "\"") {
+					names = append(names, v)
+				}
+			}
+			if len(names) > 2 {
+				in.Error(action, fmt.Sprintf("%d variables were provided, expected not more than 2!", len(names)), "arg_count")
+				return true
 			}
+			ignored := in.IgnoreErr
 			in.IgnoreErr = true
 			err := in.Run(action.Target)
-			switch len(action.Variables) {
+			caught := in.Err
+			in.Err = nil
+			if !err {
+				caught = nil
+			} else if caught == nil {
+				caught = &RuntimeError{Type: "unknown", Message: "execution failed", Action: action.Type}
+			}
+			if caught != nil && !caught.Matches(action.Variables) {
+				// not one of the listed types, keep unwinding
+				in.IgnoreErr = ignored
+				in.Err = caught
+				if !ignored {
+					io.WriteString(in.stderr(), caught.Traceback())
+				}
+				return true
+			}
+			if caught != nil && in.run != nil && (caught.Type == "interrupt" || caught.Type == "timeout") && in.run.granted.CompareAndSwap(false, true) {
+				in.run.grace.Store(interruptGrace)
+			}
+			switch len(names) {
 			case 1:
-				in.Save(string(action.Variables[0]), err)
+				in.Save(string(names[0]), err)
 			case 2:
-				in.Save(string(action.Variables[0]), err)
-				// fmt.Printf("Runtime error: %s\nLocation: line %d\nAction: %s\nType: %s\nLine:\n%s\n", message, act.Source.N+1, act.Type, etype, strings.ReplaceAll(act.Source.Source, "\r\n", "\n"))
-				p := bytecode.Pair{}
-				p.Ids = make(map[string]*bytecode.MinPtr)
-				PairAppend(&p, in, big.NewInt(int64(in.ErrSource.N)+1), "line")
-				PairAppend(&p, in, in.ErrSource.Source, "source")
-				PairAppend(&p, in, error_type, "action")
-				PairAppend(&p, in, error_type, "type")
-				PairAppend(&p, in, error_message, "message")
-				error_type = ""
-				error_message = ""
-				in.Save(string(action.Variables[1]), p)
-			}
-			in.IgnoreErr = false
+				in.Save(string(names[0]), err)
+				if caught != nil {
+					in.Save(string(names[1]), in.errorPair(caught))
+				} else {
+					in.Nothing(string(names[1]))
+				}
+			}
+			in.IgnoreErr = ignored
 		case "except":
-			e := in.CheckArgN(action, 2, 2)
+			e := in.CheckArgN(action, 1, 3)
 			if e {
 				return e
 			}
+			if len(action.Variables) == 1 {
+				// re-raising the info pair of an error statement
+				e = in.CheckDtype(action, 0, PAIR)
+				if e {
+					return e
+				}
+				caught, go_err := in.PairError(in.NamedPair(action.First()))
+				if go_err != nil {
+					in.Error(action, go_err.Error(), "value")
+					return true
+				}
+				in.Raise(action, caught)
+				return true
+			}
 			e = in.CheckDtype(action, 0, STR)
 			if e {
 				return e
@@ -2968,24 +3173,48 @@ func (in *Interpreter) Run(node_name string) bool {
 			if e {
 				return e
 			}
-			in.Error(action, in.NamedStr(action.First()), in.NamedStr(action.Second()))
+			raised := &RuntimeError{Type: in.NamedStr(action.Second()), Message: in.NamedStr(action.First())}
+			if len(action.Variables) == 3 {
+				e = in.CheckDtype(action, 2, PAIR)
+				if e {
+					return e
+				}
+				raised.Payload = in.ToGo(in.GetAny(string(action.Variables[2])))
+			}
+			in.Raise(action, raised)
 			return true
 		case "$":
+			if in.forbidden(action, "exec", "") {
+				return true
+			}
 			text_command := strings.TrimSpace(strings.SplitN(action.Source.Source, "$", 2)[1])
-			arguments := in.Parse(text_command)
+			arguments, failed := in.Parse(action, text_command)
+			if failed {
+				return true
+			}
 			cmd := exec.Command(arguments[0], arguments[1:]...)
-			cmd.Stderr = os.Stderr
-			cmd.Stdin = os.Stdin
-			cmd.Stdout = os.Stdout
+			cmd.Stderr = in.counted(in.stderr())
+			cmd.Stdin = in.stdin()
+			cmd.Stdout = in.stdout()
 			go_err := cmd.Run()
+			if full(cmd.Stdout) || full(cmd.Stderr) {
+				return in.quotaError(action, "output", in.quota.limits.Output)
+			}
 			if go_err != nil {
 				in.Error(action, fmt.Sprintf("Error executing command: %v", go_err), "sys")
 				return true
 			}
 		case "$$":
+			if in.forbidden(action, "exec", "") {
+				return true
+			}
 			text_command := strings.TrimSpace(strings.SplitN(action.Source.Source, "$", 2)[1])
-			arguments := in.Parse(text_command)
+			arguments, failed := in.Parse(action, text_command)
+			if failed {
+				return true
+			}
 			cmd := exec.Command(arguments[0], arguments[1:]...)
+			cmd.Stdin = in.stdin()
 			out, go_err := cmd.CombinedOutput()
 			if go_err != nil {
 				in.Error(action, fmt.Sprintf("Error executing command: %v", go_err), "sys")
@@ -2995,6 +3224,9 @@ func (in *Interpreter) Run(node_name string) bool {
 			}
 		case "GC":
 			in.GCE()
+			if in.quota != nil && focus > 0 && in.overHeap(actions[focus-1]) {
+				return true
+			}
 		default:
 			fn := in.NamedFunc(action.Type) //in.GetAny(action.Type).(*bytecode.Function) //in.V.Funcs[in.V.Names[actions[focus].Type]]
 			// TODO: add boundcheck
@@ -3003,16 +3235,33 @@ func (in *Interpreter) Run(node_name string) bool {
 					in.Error(actions[focus], "Undeclared function!", "undeclared")
 				}
 			*/
-			switch fn.Name {
+			if fn.Struct != nil && fn.Node == "" {
+				if in.construct(fn, action) {
+					return true
+				}
+				break
+			}
+			if _, named, _ := bytecode.SplitArgs(action.Variables); fn.Node == "" && len(named) > 0 {
+				in.Error(action, fmt.Sprintf("%s takes no named arguments!", action.Type), "arg_count")
+				return true
+			}
+			// a user function named like a builtin shadows it
+			switch ternary(fn.Node == "", fn.Name, "") {
 			case "print", "out":
+				parts := make([]string, len(action.Variables))
 				for n, v := range action.Variables {
-					fmt.Print(in.Stringify(in.GetAny(string(v))))
-					if n != len(action.Variables)-1 {
-						fmt.Print(" ")
+					text, err := in.show(action, in.GetAny(string(v)))
+					if err {
+						return true
 					}
+					parts[n] = text
 				}
+				text := strings.Join(parts, " ")
 				if action.Type == "print" {
-					fmt.Println()
+					text += "\n"
+				}
+				if _, err := io.WriteString(in.stdout(), text); in.overOutput(action, err) {
+					return true
 				}
 			case "replace":
 				err := in.CheckArgN(action, 3, 4)
@@ -3049,13 +3298,34 @@ func (in *Interpreter) Run(node_name string) bool {
 				if err {
 					return true
 				}
-				b, ferr := os.ReadFile(in.NamedStr(string(action.Variables[0])))
-				if ferr != nil {
-					in.Error(action, ferr.Error(), "sys")
-					return true
+				b, bundled := Bundled[in.NamedStr(action.First())]
+				if !bundled {
+					if in.forbidden(action, "read", in.NamedStr(action.First())) {
+						return true
+					}
+					var ferr error
+					b, ferr = os.ReadFile(in.NamedStr(string(action.Variables[0])))
+					if ferr != nil {
+						in.Error(action, ferr.Error(), "sys")
+						return true
+					}
+				}
+				var last_node string
+				if bytecode.IsCompiled(b) {
+					unit, derr := bytecode.Decode(b)
+					if derr != nil {
+						in.Error(action, in.NamedStr(action.First())+": "+derr.Error(), "value")
+						return true
+					}
+					last_node = in.Link(unit)
+				} else {
+					var serr error
+					last_node, serr = in.Compile(string(b), in.NamedStr(string(action.Variables[0])))
+					if serr != nil {
+						in.Error(action, in.NamedStr(action.First())+": "+serr.Error(), "syntax")
+						return true
+					}
 				}
-				in.Compile(string(b), in.NamedStr(string(action.Variables[0])))
-				last_node := fmt.Sprintf("_node_%d", bytecode.NodeN-1)
 				err = in.Run(last_node)
 				if err {
 					return err
@@ -3069,6 +3339,9 @@ func (in *Interpreter) Run(node_name string) bool {
 				if err {
 					return true
 				}
+				if in.forbidden(action, "native", "") {
+					return true
+				}
 				go_err := in.LaunchExe(in.NamedStr(action.First()), "")
 				if go_err != nil {
 					in.Error(action, go_err.Error(), "rpc")
@@ -3084,8 +3357,11 @@ func (in *Interpreter) Run(node_name string) bool {
 					return true
 				}
 				c := in.NamedStr(action.First())
-				in.Compile(c, "\""+c+"\"")
-				last_node := fmt.Sprintf("_node_%d", bytecode.NodeN-1)
+				last_node, serr := in.Compile(c, "\""+c+"\"")
+				if serr != nil {
+					in.Error(action, serr.Error(), "syntax")
+					return true
+				}
 				err = in.Run(last_node)
 				if err {
 					return err
@@ -3100,25 +3376,22 @@ func (in *Interpreter) Run(node_name string) bool {
 					return true
 				}
 				c := in.NamedStr(action.First())
-				var nodes []string
-				for node_name := range in.Code {
-					nodes = append(nodes, node_name)
+				last_node, serr := in.Compile(c, "\""+c+"\"")
+				if serr != nil {
+					in.Error(action, serr.Error(), "syntax")
+					return true
 				}
-				in.Compile(c, "\""+c+"\"")
-				last_node := fmt.Sprintf("_node_%d", bytecode.NodeN-1)
-				if len(in.Code[last_node]) > 1 {
-					in.Code[last_node] = in.Code[last_node][:len(in.Code[last_node])-1] // let's remove GC action
+				last_acts, _ := in.Node(last_node)
+				if len(last_acts) > 1 {
+					last_acts = last_acts[:len(last_acts)-1] // let's remove GC action
+					in.SetNode(last_node, last_acts)
 				}
 				err = in.Run(last_node)
 				if err {
 					return err
 				}
-				in.Save(action.Target, in.GetAny(in.Code[last_node][len(in.Code[last_node])-1].Target))
-				for node_name := range in.Code {
-					if !bytecode.Has(nodes, node_name) {
-						delete(in.Code, node_name)
-					}
-				}
+				in.Save(action.Target, in.GetAny(last_acts[len(last_acts)-1].Target))
+				in.Forget(last_node)
 			case "isdir":
 				err := in.CheckArgN(action, 1, 1)
 				if err {
@@ -3128,6 +3401,9 @@ func (in *Interpreter) Run(node_name string) bool {
 				if err {
 					return true
 				}
+				if in.forbidden(action, "read", in.NamedStr(action.First())) {
+					return true
+				}
 				is_dir, go_err := isDirectory(in.NamedStr(action.First()))
 				if go_err != nil {
 					in.Error(action, go_err.Error(), "sys")
@@ -3184,7 +3460,10 @@ func (in *Interpreter) Run(node_name string) bool {
 				if err {
 					return true
 				}
-				str := in.Fmt(in.NamedStr(string(action.Variables[0])))
+				str, failed := in.Fmt(action, in.NamedStr(string(action.Variables[0])))
+				if failed {
+					return true
+				}
 				in.Save(action.Target, str)
 			case "lower":
 				err := in.CheckArgN(action, 1, 1)
@@ -3224,28 +3503,24 @@ func (in *Interpreter) Run(node_name string) bool {
 				f_in := Interpreter{V: &Vars{
 					Names: make(map[string]int),
 				}}
+				f_in.Id = rand.Uint64()
 				f_in.Copy(in)
 				fn := in.NamedFunc(action.Second())
 				for _, ptr := range l.Ids {
 					a := in.GetAnyRef(ptr)
 					if fn.Node != "" {
-						f_in.Save(string(fn.Vars[0]), a)
-						min_err := f_in.Run(fn.Node)
+						c_in, min_err := in.callback(fn, action, a)
 						if min_err {
 							return true
 						}
-						if _, ok := f_in.V.Names["_return_"]; ok {
-							ListAppend(&l_out, in, f_in.GetAny("_return_"))
+						if _, ok := c_in.V.Names["_return_"]; ok {
+							ListAppend(&l_out, in, in.returned(c_in))
 						} else {
 							in.Nothing("Nothing")
-							nptr := &bytecode.MinPtr{uint64(in.GetSlot("Nothing").Index), in.Id}
+							nptr := &bytecode.MinPtr{Addr: uint64(in.GetSlot("Nothing").Index), Id: in.Id}
 							l_out.Ids = append(l_out.Ids, nptr)
 						}
-						f_in.Destroy()
-						f_in = Interpreter{V: &Vars{
-							Names: make(map[string]int),
-						}}
-						f_in.Copy(in)
+						c_in.Destroy()
 					} else {
 						f_in.Save("_item_", a)
 						act2 := bytecode.Action{}
@@ -3255,13 +3530,14 @@ func (in *Interpreter) Run(node_name string) bool {
 						act2.Type = fn.Name
 						min_err := f_in.Run(act2.String())
 						if min_err {
+							in.Err = f_in.Err
 							return true
 						}
 						if _, ok := f_in.V.Names["_return_"]; ok {
 							ListAppend(&l_out, in, f_in.GetAny("_return_"))
 						} else {
 							in.Nothing("Nothing")
-							nptr := &bytecode.MinPtr{uint64(in.GetSlot("Nothing").Index), in.Id}
+							nptr := &bytecode.MinPtr{Addr: uint64(in.GetSlot("Nothing").Index), Id: in.Id}
 							l_out.Ids = append(l_out.Ids, nptr)
 						}
 					}
@@ -3273,6 +3549,9 @@ func (in *Interpreter) Run(node_name string) bool {
 				if err {
 					return err
 				}
+				if in.forbidden(action, "env", "") {
+					return true
+				}
 				if len(action.Variables) == 2 {
 					err = in.CheckDtype(action, 0, STR)
 					if err {
@@ -3323,7 +3602,7 @@ func (in *Interpreter) Run(node_name string) bool {
 						case INT:
 							in.Save(action.Target, in.NamedInt(string(action.Variables[0])).String())
 						case FLOAT:
-							in.Save(action.Target, in.NamedFloat(string(action.Variables[0])).String())
+							in.Save(action.Target, in.floatText(in.NamedFloat(string(action.Variables[0]))))
 						case BYTE:
 							in.Save(action.Target, fmt.Sprintf("b.%d", in.NamedByte(string(action.Variables[0]))))
 						case BOOL:
@@ -3360,8 +3639,14 @@ func (in *Interpreter) Run(node_name string) bool {
 					case FLOAT:
 						switch in.Type(action.First()) {
 						case INT:
-							v, _ := in.NamedInt(string(action.Variables[0])).Float64()
-							in.Save(action.Target, big.NewFloat(v))
+							in.Save(action.Target, in.newFloat().SetInt(in.NamedInt(string(action.Variables[0]))))
+						case STR:
+							f, ok := in.parseFloat(in.NamedStr(action.First()))
+							if !ok {
+								in.Error(action, "invalid float: "+in.NamedStr(action.First()), "value")
+								return true
+							}
+							in.Save(action.Target, f)
 						case BYTE:
 							in.Save(action.Target, big.NewFloat(float64(in.NamedByte(string(action.Variables[0])))))
 						}
@@ -3423,10 +3708,7 @@ func (in *Interpreter) Run(node_name string) bool {
 					return true
 				}
 				id := in.NamedId(action.First()) //in.GetAny(string(action.Variables[0])).(*bytecode.MinPtr)
-				interp := in
-				for interp.Id != id.Id {
-					interp = interp.Parent
-				}
+				interp := in.owner(id.Id)
 				in.Save(action.Target, interp.GetAnyRef(id))
 			case "read":
 				err := in.CheckArgN(action, 1, 1)
@@ -3437,6 +3719,9 @@ func (in *Interpreter) Run(node_name string) bool {
 				if err {
 					return true
 				}
+				if in.forbidden(action, "read", in.NamedStr(action.First())) {
+					return true
+				}
 				b, berr := os.ReadFile(in.NamedStr(action.First()))
 				if berr != nil {
 					in.Error(action, berr.Error(), "file")
@@ -3450,10 +3735,6 @@ func (in *Interpreter) Run(node_name string) bool {
 				// }
 				// in.Save(action.Target, a)
 			case "write":
-				if IsSafe {
-					in.Error(action, "cannot write to files when in safe mode!", "permission")
-					return true
-				}
 				err := in.CheckArgN(action, 2, 2)
 				if err {
 					return true
@@ -3462,6 +3743,9 @@ func (in *Interpreter) Run(node_name string) bool {
 				if err {
 					return true
 				}
+				if in.forbidden(action, "write", in.NamedStr(action.First())) {
+					return true
+				}
 				err = in.CheckDtype(action, 1, SPAN, STR)
 				if err {
 					return true
@@ -3492,6 +3776,9 @@ func (in *Interpreter) Run(node_name string) bool {
 				if err {
 					return true
 				}
+				if in.forbidden(action, "write", in.NamedStr(action.First())) {
+					return true
+				}
 				go_err := os.MkdirAll(in.NamedStr(action.First()), 0777)
 				if go_err != nil {
 					in.Error(action, go_err.Error(), "sys")
@@ -3506,6 +3793,9 @@ func (in *Interpreter) Run(node_name string) bool {
 				if err {
 					return true
 				}
+				if in.forbidden(action, "write", in.NamedStr(action.First())) {
+					return true
+				}
 				go_err := os.Remove(in.NamedStr(action.First()))
 				if go_err != nil {
 					in.Error(action, go_err.Error(), "sys")
@@ -3516,6 +3806,13 @@ func (in *Interpreter) Run(node_name string) bool {
 				if err {
 					return true
 				}
+				if n, ok, err := in.protocol(action, in.GetAny(string(action.Variables[0])), "_len", "int"); ok {
+					if err {
+						return true
+					}
+					in.Save(action.Target, n)
+					break
+				}
 				err = in.CheckDtype(action, 0, STR, LIST, SPAN)
 				if err {
 					return true
@@ -3529,6 +3826,10 @@ func (in *Interpreter) Run(node_name string) bool {
 					s := in.NamedSpan(string(action.Variables[0]))
 					in.Save(action.Target, big.NewInt(int64(s.Length)))
 				}
+			case "precision":
+				if in.precision(action) {
+					return true
+				}
 			case "sleep":
 				err := in.CheckArgN(action, 1, 1)
 				if err {
@@ -3540,12 +3841,15 @@ func (in *Interpreter) Run(node_name string) bool {
 				}
 				switch in.Type(action.First()) {
 				case INT:
-					time.Sleep(time.Duration(in.NamedInt(action.First()).Int64()) * 1000 * time.Millisecond)
+					in.sleep(time.Duration(in.NamedInt(action.First()).Int64()) * 1000 * time.Millisecond)
 				case FLOAT:
 					f, _ := in.NamedFloat(action.First()).Float64()
-					time.Sleep(time.Duration(int64(f*1000)) * time.Millisecond)
+					in.sleep(time.Duration(int64(f*1000)) * time.Millisecond)
 				case BYTE:
-					time.Sleep(time.Duration(int64(in.NamedByte(action.First()))) * 1000 * time.Millisecond)
+					in.sleep(time.Duration(int64(in.NamedByte(action.First()))) * 1000 * time.Millisecond)
+				}
+				if in.run != nil && in.interrupted(action) {
+					return true
 				}
 			case "range":
 				err := in.CheckArgN(action, 1, 3)
@@ -3642,18 +3946,11 @@ func (in *Interpreter) Run(node_name string) bool {
 						return err
 					}
 					fn = *in.NamedFunc(action.Second())
-					if fn.Node != "" && len(fn.Vars) == 1 {
+					if fn.Node != "" {
 						mask := bytecode.List{}
 						for ptr := 0; ptr < len(in.NamedList(action.First()).Ids); ptr++ {
 							// user functions start
-							f_in := Interpreter{V: &Vars{
-								Names: make(map[string]int),
-							}}
-							f_in.Id = rand.Uint64()
-							f_in.Copy(in)
-							f_in.Save(string(fn.Vars[0]), in.GetAnyRef(in.NamedList(action.First()).Ids[ptr]))
-							err := f_in.Run(fn.Node)
-							in.ErrSource = f_in.ErrSource
+							f_in, err := in.callback(&fn, action, in.GetAnyRef(in.NamedList(action.First()).Ids[ptr]))
 							if err {
 								return err
 							}
@@ -3662,13 +3959,13 @@ func (in *Interpreter) Run(node_name string) bool {
 								f_in.Nothing("_return_")
 							}
 							if f_in.V.Slots[f_in.V.Names["_return_"]].Type == LIST {
-								l := in.CopyList("_return_", &f_in)
+								l := in.CopyList("_return_", f_in)
 								ListAppend(&mask, in, l)
 							} else if f_in.V.Slots[f_in.V.Names["_return_"]].Type == SPAN {
-								l := in.CopySpan("_return_", &f_in)
+								l := in.CopySpan("_return_", f_in)
 								ListAppend(&mask, in, l)
 							} else if f_in.V.Slots[f_in.V.Names["_return_"]].Type == PAIR {
-								l := in.CopyPair("_return_", &f_in)
+								l := in.CopyPair("_return_", f_in)
 								ListAppend(&mask, in, l)
 							} else {
 								ListAppend(&mask, in, f_in.GetAny("_return_"))
@@ -3710,7 +4007,7 @@ func (in *Interpreter) Run(node_name string) bool {
 							action2.Target = "_return_"
 							action2.Source = action.Source
 							err := f_in.Run(action2.String())
-							in.ErrSource = f_in.ErrSource
+							in.Err = f_in.Err
 							if err {
 								return err
 							}
@@ -3756,8 +4053,8 @@ func (in *Interpreter) Run(node_name string) bool {
 						node_name_sort := fmt.Sprintf("_runner_%x", rand.Int64())
 						target_sort := fmt.Sprintf("_targ_%x", rand.Int64())
 						arguments := []bytecode.Variable{("item")}
-						in.Code[node_name_sort] = []bytecode.Action{{Target: target_sort, Type: fn.Name, Variables: arguments, Source: action.Source},
-							{Type: "return", Variables: []bytecode.Variable{bytecode.Variable(target_sort)}, Source: action.Source}}
+						in.SetNode(node_name_sort, []bytecode.Action{{Target: target_sort, Type: fn.Name, Variables: arguments, Source: action.Source},
+							{Type: "return", Variables: []bytecode.Variable{bytecode.Variable(target_sort)}, Source: action.Source}})
 						mask := bytecode.List{}
 						for ptr := 0; ptr < len(in.NamedList(action.First()).Ids); ptr++ {
 							// user functions start
@@ -3768,7 +4065,7 @@ func (in *Interpreter) Run(node_name string) bool {
 							f_in.Copy(in)
 							f_in.Save("item", in.GetAnyRef(in.NamedList(action.First()).Ids[ptr]))
 							err := f_in.Run(node_name_sort)
-							in.ErrSource = f_in.ErrSource
+							in.Err = f_in.Err
 							if err {
 								return err
 							}
@@ -3814,6 +4111,11 @@ func (in *Interpreter) Run(node_name string) bool {
 						in.Error(actions[focus], "Undeclared function!", "undeclared")
 						return true
 					}
+				} else if sorted_l, ok, err := in.sortLess(action, in.NamedList(action.First())); ok {
+					if err {
+						return true
+					}
+					in.Save(action.Target, sorted_l)
 				} else {
 					sorted_l, sort_err := in.SortList(in.NamedList(action.First()))
 					if sort_err != nil {
@@ -3838,9 +4140,11 @@ func (in *Interpreter) Run(node_name string) bool {
 				if err {
 					return err
 				}
-				RL.SetPrompt(in.NamedStr(string(action.Variables[0])))
-				str, err_ := RL.Readline()
-				if err_ != nil {
+				str, err_ := in.readLine(in.NamedStr(string(action.Variables[0])))
+				if err_ == io.EOF && in.Stdin != nil {
+					in.Error(action, "end of input!", "sys")
+					return true
+				} else if err_ != nil {
 					in.Error(action, "keyboard interrupt!", "interrupt")
 					return true
 				}
@@ -3878,7 +4182,7 @@ func (in *Interpreter) Run(node_name string) bool {
 					exe, _ := os.Executable()
 					in.Save(action.Target, exe)
 				case "version":
-					in.Save(action.Target, "4.3.7")
+					in.Save(action.Target, bytecode.Version)
 				case "args":
 					l := bytecode.List{}
 					for _, arg := range os.Args {
@@ -3911,7 +4215,7 @@ func (in *Interpreter) Run(node_name string) bool {
 					}
 					in.Save(action.Target, fs)
 				case "vars":
-					vs := bytecode.Pair{make(map[string]*bytecode.MinPtr)}
+					vs := bytecode.Pair{Ids: make(map[string]*bytecode.MinPtr)}
 					interp := in
 					for interp != nil {
 						for name, i := range interp.V.Names {
@@ -3958,6 +4262,9 @@ func (in *Interpreter) Run(node_name string) bool {
 				if err {
 					return true
 				}
+				if in.forbidden(action, "read", in.NamedStr(action.First())) {
+					return true
+				}
 				os.Chdir(in.NamedStr(string(action.Variables[0])))
 			case "glob":
 				err := in.CheckArgN(action, 1, 1)
@@ -3975,7 +4282,9 @@ func (in *Interpreter) Run(node_name string) bool {
 				}
 				l := bytecode.List{}
 				for _, file := range files {
-					ListAppend(&l, in, file)
+					if in.Caps == nil || in.Caps.Read.Allows(file) {
+						ListAppend(&l, in, file)
+					}
 				}
 				in.Save(action.Target, l)
 			case "rget":
@@ -3986,6 +4295,9 @@ func (in *Interpreter) Run(node_name string) bool {
 				if in.CheckDtype(action, 0, STR) {
 					return true
 				}
+				if in.forbidden(action, "net", "") {
+					return true
+				}
 				url := in.NamedStr(string(action.Variables[0]))
 				resp, err2 := http.Get(url)
 				if err2 != nil {
@@ -4015,6 +4327,9 @@ func (in *Interpreter) Run(node_name string) bool {
 				if in.CheckDtype(action, 0, STR) && in.CheckDtype(action, 1, PAIR) {
 					return true
 				}
+				if in.forbidden(action, "net", "") {
+					return true
+				}
 				url := in.NamedStr(action.First())
 				pair := in.NamedPair(action.Second())
 				// jsonStr := PairString(&pair, in)
@@ -4119,6 +4434,9 @@ func (in *Interpreter) Run(node_name string) bool {
 				if err {
 					return err
 				}
+				if in.forbidden(action, "read", in.NamedStr(action.First())) {
+					return true
+				}
 				info, go_err := os.Stat(in.NamedStr(action.First()))
 				if go_err != nil {
 					in.Error(action, go_err.Error(), "sys")
@@ -4144,17 +4462,14 @@ func (in *Interpreter) Run(node_name string) bool {
 						_, ok = interp.V.Names[action.First()]
 					}
 					// fmt.Println("Pointer:", interp.V.Names[action.First()], interp.Id)
-					in.Save(action.Target, &bytecode.MinPtr{uint64(interp.V.Names[action.First()]), interp.Id})
+					in.Save(action.Target, &bytecode.MinPtr{Addr: uint64(interp.V.Names[action.First()]), Id: interp.Id})
 				} else {
 					err = in.CheckDtype(action, 1, ID)
 					if err {
 						return err
 					}
-					interp := in
 					ptr := in.NamedId(action.Second())
-					for interp.Id != ptr.Id {
-						interp = interp.Parent
-					}
+					interp := in.owner(ptr.Id)
 					interp.SaveRef(ptr, in.GetAny(action.First()))
 					// newptr := interp.SaveRefNew(in.GetAny(action.First()))
 					// interp.V.Slots[ptr.Addr] = interp.V.Slots[newptr.Addr]
@@ -4225,6 +4540,17 @@ func (in *Interpreter) Run(node_name string) bool {
 				if err {
 					return err
 				}
+				if items, ok, err := in.protocol(action, in.GetAny(string(action.Variables[0])), "_iter", "list"); ok {
+					if err {
+						return true
+					}
+					found, err := in.contains(action, items.(bytecode.List), in.GetAny(string(action.Variables[1])))
+					if err {
+						return true
+					}
+					in.Save(action.Target, found)
+					break
+				}
 				err = in.CheckDtype(action, 0, STR, LIST, SPAN)
 				if err {
 					return err
@@ -4237,17 +4563,11 @@ func (in *Interpreter) Run(node_name string) bool {
 					}
 					in.Save(action.Target, strings.Contains(in.NamedStr(string(action.Variables[0])), in.NamedStr(string(action.Variables[1]))))
 				case LIST:
-					in.Save(action.Target, false)
-					l := in.NamedList(string(action.Variables[0]))
-					for n := range len(l.Ids) {
-						in.Save("_cmp_0", in.GetAnyRef(l.Ids[n]))
-						in.Save("_cmp_1", in.GetAny(string(action.Variables[1])))
-						o, t := in.EqualizeTypes("_cmp_0", "_cmp_1")
-						if equals := in.CompareName(o, t); equals {
-							in.Save(action.Target, true)
-							break
-						}
+					found, err := in.contains(action, in.NamedList(string(action.Variables[0])), in.GetAny(string(action.Variables[1])))
+					if err {
+						return true
 					}
+					in.Save(action.Target, found)
 				case SPAN:
 					in.Save(action.Target, false)
 					s := in.NamedSpan(string(action.Variables[0]))
@@ -4323,64 +4643,26 @@ func (in *Interpreter) Run(node_name string) bool {
 				if err {
 					return err
 				}
-				in.Save(action.Target, map[byte]string{NOTH: "noth", INT: "int", FLOAT: "float", BYTE: "byte", STR: "str", FUNC: "func", SPAN: "span", ID: "id", LIST: "list", BOOL: "bool", PAIR: "pair", ARR: "arr"}[in.Type(action.First())])
+				in.Save(action.Target, in.typeOf(in.GetAny(action.First())))
+			case "doc":
+				err := in.CheckArgN(action, 1, 1)
+				if err {
+					return err
+				}
+				err = in.CheckDtype(action, 0, FUNC)
+				if err {
+					return err
+				}
+				in.Save(action.Target, in.GetAny(action.First()).(*bytecode.Function).Doc)
 			default:
 				if fn.Node != "" {
-					// user functions start
-					f_in := Interpreter{V: &Vars{
-						Names: make(map[string]int),
-					}}
-					f_in.Id = rand.Uint64()
-					f_in.Copy(in)
-					for n, fn_arg := range fn.Vars {
-						if n == len(fn.Vars)-1 && len(action.Variables) > len(fn.Vars) {
-							last := bytecode.List{}
-							for _, lastlet := range action.Variables[len(fn.Vars)-1:] {
-								ListAppend(&last, &f_in, in.GetAny(string(lastlet)))
-							}
-							f_in.Save(string(fn_arg), last)
-							continue
-						}
-						fn_arg_str := string(fn_arg)
-						if in.Type(string(action.Variables[n])) == PAIR {
-							p := f_in.CopyPair(string(action.Variables[n]), in)
-							f_in.Save(fn_arg_str, p)
-						} else if in.Type(string(action.Variables[n])) == LIST {
-							l := f_in.CopyList(string(action.Variables[n]), in)
-							f_in.Save(fn_arg_str, l)
-						} else if in.Type(string(action.Variables[n])) == ID {
-							id := in.NamedId(string(action.Variables[n]))
-							f_in.Save(fn_arg_str, id)
-						} else if in.Type(string(action.Variables[n])) == SPAN {
-							s := f_in.CopySpan(string(action.Variables[n]), in)
-							f_in.Save(fn_arg_str, s)
-						} else {
-							f_in.Save(fn_arg_str, in.GetAny(string(action.Variables[n])))
-						}
-					}
-					err := f_in.Run(fn.Node)
-					in.ErrSource = f_in.ErrSource
-					if err {
-						return err
-					}
-					_, ok := f_in.V.Names["_return_"]
-					if !ok {
-						f_in.Nothing("_return_")
+					if in.invoke(fn, action) {
+						return true
 					}
-					if f_in.V.Slots[f_in.V.Names["_return_"]].Type == LIST {
-						l := in.CopyList("_return_", &f_in)
-						in.Save(action.Target, l)
-					} else if f_in.V.Slots[f_in.V.Names["_return_"]].Type == PAIR {
-						l := in.CopyPair("_return_", &f_in)
-						in.Save(action.Target, l)
-					} else if f_in.V.Slots[f_in.V.Names["_return_"]].Type == SPAN {
-						l := in.CopySpan("_return_", &f_in)
-						in.Save(action.Target, l)
-					} else {
-						in.Save(action.Target, f_in.GetAny("_return_"))
+				} else if host, ok := in.Hosts[fn.Name]; ok && fn.Name != "" {
+					if in.callHost(host, action) {
+						return true
 					}
-					f_in.Destroy()
-					// user functions end
 				} else {
 					_, ok := functionMap[action.Type]
 					if !ok {
@@ -4436,6 +4718,11 @@ func (in *Interpreter) DeepAssign(receiver any, item any, inds []any) error {
 			}
 		}
 	case *bytecode.Pair:
+		if rec.Struct != nil {
+			if err := in.assign(rec, item, inds); err != nil {
+				return err
+			}
+		}
 		if len(inds) == 1 {
 			mainkey := PairKey(in, inds[0])
 			if _, ok := rec.Ids[mainkey]; ok {
@@ -4445,10 +4732,8 @@ func (in *Interpreter) DeepAssign(receiver any, item any, inds []any) error {
 			}
 		} else {
 			mainkey := PairKey(in, inds[0])
-			if _, ok := rec.Ids[mainkey]; ok {
-				rec.Ids[mainkey] = in.GetRef(item)
-			} else {
-				PairAppend(rec, in, item, inds[0])
+			if _, ok := rec.Ids[mainkey]; !ok {
+				return fmt.Errorf("invalid pairing key: %s", strings.SplitN(mainkey, ":", 2)[1])
 			}
 			if in.V.Slots[rec.Ids[mainkey].Addr].Type == LIST {
 				sublist := in.GetAnyRef(rec.Ids[mainkey]).(bytecode.List)
@@ -4591,6 +4876,15 @@ func PoolWorkerNewLegacy(
 
 // UTIL FUNCTIONS
 func (in *Interpreter) Nothing(name string) {
+	slot_id, ok := in.V.Names[name]
+	in.nothing(name, slot_id, ok)
+}
+
+// nothing is Nothing given the slot of name, if it has one.
+func (in *Interpreter) nothing(name string, slot_id int, ok bool) {
+	if ok && in.V.Slots[slot_id].Type == NOTH {
+		return // already Nothing, as most action targets are before they run
+	}
 	interp := in
 	for interp.Parent != nil {
 		if slot_id, ok := interp.V.Names["Nothing"]; !ok {
@@ -4639,7 +4933,7 @@ func (in *Interpreter) IndexList(l bytecode.List, ind_any any) (any, error) {
 
 func (in *Interpreter) TypeRef(ref *bytecode.MinPtr) byte {
 	if ref.Id != in.Id {
-		return in.Parent.TypeRef(ref)
+		return in.owner(ref.Id).TypeRef(ref)
 	}
 	return in.V.Slots[ref.Addr].Type
 }
@@ -4713,11 +5007,29 @@ func (in *Interpreter) Compare(v0, v1 any) bool {
 	return false
 }
 
+// shareRun gives child what every scope of a run shares: its context, quotas,
+// capabilities, float settings, streams, host functions and modules.
+func (in *Interpreter) shareRun(child *Interpreter) {
+	child.run = in.run
+	child.quota = in.quota
+	child.Caps = in.Caps
+	child.prec = in.prec
+	child.Stdout, child.Stderr, child.Stdin = in.Stdout, in.Stderr, in.Stdin
+	child.Hosts = in.Hosts
+	child.loaded = in.imports()
+}
+
 func (in *Interpreter) Copy(og *Interpreter) {
+	og.shareRun(in)
 	in.IgnoreErr = og.IgnoreErr
+	in.depth = og.depth + 1
+	in.fn = og.fn
 	in.Code = og.Code
 	in.File = og.File
 	in.Parent = og
+	if in.V.gcMax == 0 {
+		in.V.gcMax = og.V.gcMax // otherwise every line of the call compacts
+	}
 	/*
 		for key := range og.V.Names {
 			in.Save(key, og.GetAny(key))
@@ -4725,10 +5037,77 @@ func (in *Interpreter) Copy(og *Interpreter) {
 	*/
 }
 
+// declare builds the function that the func action at focus declares, with
+// the doc of the ## action before it.
+func (in *Interpreter) declare(actions []bytecode.Action, focus int) *bytecode.Function {
+	action := actions[focus]
+	params := bytecode.ParseParams(action.Variables[1:])
+	fn := &bytecode.Function{Name: action.First(), Target: action.Target, Vars: bytecode.ParamNames(params), Params: params, Node: action.Target}
+	if focus > 0 && actions[focus-1].Type == "##" {
+		fn.Doc = bytecode.DocText(actions[focus-1])
+	}
+	if in.Parent != nil {
+		fn.Env = in
+	}
+	return fn
+}
+
+// enter turns in, the scope of a call to fn just copied from its caller, into
+// a closure scope when fn captured the scope it was defined in: names are
+// looked up there, while traces and references still reach the caller.
+func (in *Interpreter) enter(fn *bytecode.Function) {
+	if env, ok := fn.Env.(*Interpreter); ok {
+		in.caller, in.Parent = in.Parent, env
+		in.File = env.File
+	}
+}
+
+// returned gives the value a finished call scope returned, its lists, pairs
+// and spans copied into in.
+func (in *Interpreter) returned(f_in *Interpreter) any {
+	switch f_in.Type("_return_") {
+	case LIST:
+		return in.CopyList("_return_", f_in)
+	case PAIR:
+		return in.CopyPair("_return_", f_in)
+	case SPAN:
+		return in.CopySpan("_return_", f_in)
+	}
+	return f_in.GetAny("_return_")
+}
+
+// up returns the scope that called in, which for a closure is not its parent.
+func (in *Interpreter) up() *Interpreter {
+	if in.caller != nil {
+		return in.caller
+	}
+	return in.Parent
+}
+
+// owner returns the scope holding the slots of the references with the given
+// id, searching the parents and, from closures, the scopes calling them.
+func (in *Interpreter) owner(id uint64) *Interpreter {
+	for scope := in; scope != nil; scope = scope.Parent {
+		if scope.Id == id {
+			return scope
+		}
+		if scope.caller != nil {
+			if found := scope.caller.owner(id); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
 func (in *Interpreter) Copy2(og *Interpreter) {
+	og.shareRun(in)
 	in.IgnoreErr = og.IgnoreErr
+	in.depth = og.depth
+	in.fn = og.fn
 	in.Code = og.Code
 	in.File = og.File
+	in.origin = og
 	// TODO: verify if needed
 	for _, fn := range bytecode.GenerateFuns() {
 		if _, ok := in.V.Names[fn.Name]; ok {
@@ -4751,9 +5130,13 @@ func (in *Interpreter) Copy2(og *Interpreter) {
 }
 
 func (in *Interpreter) CopyDeep(og *Interpreter) {
+	og.shareRun(in)
 	in.IgnoreErr = og.IgnoreErr
+	in.depth = og.depth
+	in.fn = og.fn
 	in.Code = og.Code
 	in.File = og.File
+	in.origin = og
 
 	// Load built-in functions
 	for _, fn := range bytecode.GenerateFuns() {
@@ -4813,7 +5196,7 @@ func (in *Interpreter) CopyDeep(og *Interpreter) {
 
 		case bytecode.Pair:
 			// Deep copy Pair contents
-			newPair := bytecode.Pair{Ids: make(map[string]*bytecode.MinPtr)}
+			newPair := bytecode.Pair{Ids: make(map[string]*bytecode.MinPtr), Struct: v.Struct}
 			for name, id := range v.Ids {
 				elem := og.GetAnyRef(id)
 				PairAppend(&newPair, in, elem, name)
@@ -4862,7 +5245,7 @@ func (in *Interpreter) CopyListRef(vname *bytecode.MinPtr, og *Interpreter) byte
 
 func (in *Interpreter) CopyPair(vname string, og *Interpreter) bytecode.Pair {
 	l := og.GetAny(vname).(bytecode.Pair)
-	lnew := bytecode.Pair{}
+	lnew := bytecode.Pair{Struct: l.Struct}
 	lnew.Ids = make(map[string]*bytecode.MinPtr)
 	for key, id := range l.Ids {
 		a := og.GetAnyRef(id)
@@ -4879,7 +5262,7 @@ func (in *Interpreter) CopyPair(vname string, og *Interpreter) bytecode.Pair {
 
 func (in *Interpreter) CopyPairRef(vname *bytecode.MinPtr, og *Interpreter) bytecode.Pair {
 	l := og.GetAnyRef(vname).(bytecode.Pair)
-	lnew := bytecode.Pair{}
+	lnew := bytecode.Pair{Struct: l.Struct}
 	lnew.Ids = make(map[string]*bytecode.MinPtr)
 	for key, id := range l.Ids {
 		a := og.GetAnyRef(id)
@@ -4894,13 +5277,13 @@ func (in *Interpreter) CopyPairRef(vname *bytecode.MinPtr, og *Interpreter) byte
 	return lnew
 }
 
+// Destroy is called on a finished function scope. Its values are left to the
+// Go collector: compacting storage that is about to be dropped only cost a
+// forced collection per call.
 func (in *Interpreter) Destroy() {
 	//for key := range in.V.Names {
 	//	in.RemoveName(key)
 	//}
-
-	in.GCE()
-	in = &Interpreter{}
 }
 
 func (in *Interpreter) CopyListDeep(l bytecode.List, og *Interpreter) bytecode.List {
@@ -4959,9 +5342,8 @@ func (in *Interpreter) HeaderFunc(signature string) {
 			body = append(body, statement)
 		}
 		fn_full := strings.Join(append([]string{header}, body...), "\n")
-		in.Compile(fn_full, ".")
-		last_node := fmt.Sprintf("_node_%d", bytecode.NodeN-1)
-		in.Save(fn_name_args, &bytecode.Function{fn_name, last_node, in_vars, last_node})
+		last_node, _ := in.Compile(fn_full, ".") // generated, always compiles
+		in.Save(fn_name_args, &bytecode.Function{Name: fn_name, Target: last_node, Vars: in_vars, Node: last_node})
 	}
 }
 
@@ -5050,10 +5432,20 @@ func (in *Interpreter) ListToJson(l bytecode.List) []any {
 type RunRequest struct {
 	Variables []string `json:"variables"`
 	Code      string   `json:"code"`
+	Input     string   `json:"input"`
 }
 
 var ServerInterpreter *Interpreter
 
+// serverMu serializes requests, which all share ServerInterpreter.
+var serverMu sync.Mutex
+
+// ServerTimeout limits the run time of a single server request, zero means no limit.
+var ServerTimeout time.Duration
+
+// ServerLimits are applied afresh to every server request.
+var ServerLimits Limits
+
 func ServerHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -5069,45 +5461,72 @@ func ServerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if ServerInterpreter == nil {
-		ServerInterpreter = &Interpreter{
-			V:    &Vars{Names: make(map[string]int)},
-			Id:   rand.Uint64(),
-			Code: make(map[string][]bytecode.Action),
-		}
+	// compiling needs none of the shared state, so it runs outside the lock
+	// and a slow one does not hold up the other requests
+	var result map[string]any
+	if unit, err := bytecode.Compile(req.Code); err != nil {
+		result = map[string]any{"$output": "", "error": err.Error()}
+	} else {
+		result = serveUnit(r.Context(), unit, req, compile == "")
 	}
 
-	result := ServerInterpreter.RunJson(req, compile == "")
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(result)
 }
 
-func (in *Interpreter) RunJson(req RunRequest, forget bool) map[string]any {
-	// Track existing nodes so we can delete temporary ones later
-	var originalNodes []string
-	if forget {
-		for node := range in.Code {
-			originalNodes = append(originalNodes, node)
-		}
+// serveUnit runs a request compiled into unit with ServerInterpreter, one
+// request at a time.
+func serveUnit(ctx context.Context, unit bytecode.Unit, req RunRequest, forget bool) map[string]any {
+	serverMu.Lock()
+	defer serverMu.Unlock()
+	if ServerInterpreter == nil {
+		ServerInterpreter = NewInterpreterPtr("", "json")
+	}
+	if ServerTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ServerTimeout)
+		defer cancel()
 	}
+	if ServerLimits != (Limits{}) {
+		ServerInterpreter.SetLimits(ServerLimits)
+	}
+	return ServerInterpreter.runUnit(ctx, unit, req, forget)
+}
 
+func (in *Interpreter) RunJson(ctx context.Context, req RunRequest, forget bool) map[string]any {
 	// Compile the code
-	in.Compile(req.Code, "json")
-	lastNode := fmt.Sprintf("_node_%d", bytecode.NodeN-1)
+	unit, err := bytecode.Compile(req.Code)
+	if err != nil {
+		return map[string]any{"$output": "", "error": err.Error()}
+	}
+	return in.runUnit(ctx, unit, req, forget)
+}
+
+// runUnit is RunJson for the code of req compiled into unit.
+func (in *Interpreter) runUnit(ctx context.Context, unit bytecode.Unit, req RunRequest, forget bool) map[string]any {
+	lastNode := in.Link(unit)
 
 	// Remove GC action
-	if len(in.Code[lastNode]) > 1 {
-		in.Code[lastNode] = in.Code[lastNode][:len(in.Code[lastNode])-1]
+	if acts, _ := in.Node(lastNode); len(acts) > 1 {
+		in.SetNode(lastNode, acts[:len(acts)-1])
 	}
 
-	// Run the code
-	in.Run(lastNode)
+	// Run the code, capturing what it prints
+	output := strings.Builder{}
+	stdout, stderr, stdin := in.Stdout, in.Stderr, in.Stdin
+	in.Stdout, in.Stderr, in.Stdin = &output, &output, strings.NewReader(req.Input)
+	in.Err = nil
+	failed := in.RunContext(ctx, lastNode)
+	in.Stdout, in.Stderr, in.Stdin = stdout, stderr, stdin
 
 	// Collect results
 	result := make(map[string]any)
 	for _, name := range req.Variables {
+		if _, ok := in.V.Names[name]; !ok {
+			result[name] = nil
+			continue
+		}
 		val := in.GetAny(name)
 
 		switch in.Type(name) {
@@ -5123,22 +5542,21 @@ func (in *Interpreter) RunJson(req RunRequest, forget bool) map[string]any {
 
 		result[name] = val
 	}
+	// "error" is a keyword and "$" cannot start a name, so neither clashes with a variable
+	result["$output"] = output.String()
+	if failed {
+		result["error"] = in.runtimeError().Error()
+	}
 
 	if !forget {
 		return result
 	}
 	// Remove temporary compiled nodes
-	for node := range in.Code {
-		if !bytecode.Has(originalNodes, node) {
-			delete(in.Code, node)
-		}
-	}
+	in.Forget(lastNode)
 
 	return result
 }
 
-var IsSafe bool
-
 func (in *Interpreter) JsonPair(obj []byte) bytecode.Pair {
 	target := make(map[string]any)
 	json.Unmarshal(obj, &target)
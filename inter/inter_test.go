@@ -0,0 +1,41 @@
+package inter
+
+import (
+	"strings"
+	"testing"
+)
+
+// run runs source and returns what it printed, and the error it stopped
+// with or nil.
+func run(t *testing.T, source string) (string, *RuntimeError) {
+	t.Helper()
+	var out, errs strings.Builder
+	in := NewInterpreter(source, "test.min")
+	in.Stdout, in.Stderr = &out, &errs
+	if in.Run(in.Entry) {
+		return out.String(), in.Err
+	}
+	return out.String(), nil
+}
+
+// runOk is run for a source that must not fail.
+func runOk(t *testing.T, source string) string {
+	t.Helper()
+	out, err := run(t, source)
+	if err != nil {
+		t.Fatalf("%q failed: %s: %s", source, err.Type, err.Message)
+	}
+	return out
+}
+
+// runErr is run for a source that must fail with an error of the type etype.
+func runErr(t *testing.T, source, etype string) {
+	t.Helper()
+	_, err := run(t, source)
+	if err == nil {
+		t.Fatalf("%q did not fail, want a %s error", source, etype)
+	}
+	if err.Type != etype {
+		t.Fatalf("%q failed with %s: %s, want a %s error", source, err.Type, err.Message, etype)
+	}
+}
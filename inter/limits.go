@@ -0,0 +1,117 @@
+package inter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"minimum/bytecode"
+	"sync/atomic"
+)
+
+// LIMITS START
+
+// Limits caps the resources a script may use, zero meaning unlimited.
+// Actions and Output are counted for the whole run including function calls,
+// processes and pool workers, Heap applies to every scope on its own.
+type Limits struct {
+	Actions int64 // executed actions
+	Heap    int   // stored values of a scope, checked after every line
+	Depth   int   // nested function calls
+	Output  int64 // bytes written by print, out and shell commands
+}
+
+// usage is shared by an interpreter and all of its children.
+type usage struct {
+	limits  Limits
+	actions atomic.Int64
+	output  atomic.Int64
+}
+
+// SetLimits applies limits to this interpreter and the children it creates
+// from now on, resetting the counters of earlier runs.
+func (in *Interpreter) SetLimits(limits Limits) {
+	if limits == (Limits{}) {
+		in.quota = nil
+		return
+	}
+	in.quota = &usage{limits: limits}
+}
+
+func (in *Interpreter) quotaError(action bytecode.Action, limit string, value int64) bool {
+	in.Error(action, fmt.Sprintf("%s limit of %d exceeded", limit, value), "quota")
+	return true
+}
+
+// overQuota counts the action and checks the action and depth limits.
+func (in *Interpreter) overQuota(action bytecode.Action) bool {
+	limits := &in.quota.limits
+	if limits.Actions > 0 && in.quota.actions.Add(1) > limits.Actions {
+		return in.quotaError(action, "actions", limits.Actions)
+	}
+	if limits.Depth > 0 && in.depth > limits.Depth {
+		return in.quotaError(action, "depth", int64(limits.Depth))
+	}
+	return false
+}
+
+// overHeap collects garbage early when the heap limit is hit, failing only
+// when the live values alone do not fit.
+func (in *Interpreter) overHeap(action bytecode.Action) bool {
+	limit := in.quota.limits.Heap
+	if limit <= 0 || in.V.heapSize() <= limit {
+		return false
+	}
+	in.V.gcCycle = in.V.gcMax
+	in.GCE()
+	if in.V.heapSize() > limit {
+		return in.quotaError(action, "heap", int64(limit))
+	}
+	return false
+}
+
+// errOutput is returned by the writers of a run once its output limit is hit.
+var errOutput = errors.New("output limit exceeded")
+
+// quotaWriter counts the bytes written to w against the output limit, writing
+// only the part of the bytes that fits once it is reached.
+type quotaWriter struct {
+	w     io.Writer
+	quota *usage
+	full  bool // a write hit the limit
+}
+
+func (q *quotaWriter) Write(b []byte) (int, error) {
+	limit := q.quota.limits.Output
+	over := q.quota.output.Add(int64(len(b))) - limit
+	if over <= 0 {
+		return q.w.Write(b)
+	}
+	n, _ := q.w.Write(b[:max(int64(len(b))-over, 0)])
+	q.full = true
+	return n, errOutput
+}
+
+// full tells whether a write to w, a writer from counted, hit the limit.
+func full(w io.Writer) bool {
+	q, ok := w.(*quotaWriter)
+	return ok && q.full
+}
+
+// counted wraps w, a stream of the script, so that what is written to it
+// counts toward the output limit of the run.
+func (in *Interpreter) counted(w io.Writer) io.Writer {
+	if in.quota == nil || in.quota.limits.Output <= 0 {
+		return w
+	}
+	return &quotaWriter{w: w, quota: in.quota}
+}
+
+// overOutput raises the quota error of the action when err is errOutput.
+func (in *Interpreter) overOutput(action bytecode.Action, err error) bool {
+	if errors.Is(err, errOutput) {
+		return in.quotaError(action, "output", in.quota.limits.Output)
+	}
+	return false
+}
+
+// LIMITS END
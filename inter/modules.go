@@ -0,0 +1,235 @@
+package inter
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"minimum/bytecode"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// MODULES START
+
+// moduleCache holds the modules imported during a run by absolute path, so
+// that each one runs once. It is shared by an interpreter, its children and
+// its pool workers.
+type moduleCache struct {
+	mu      sync.Mutex
+	modules map[string]*Interpreter // the top level scope of each module
+	loading map[string]*moduleLoad  // modules whose top level is running
+}
+
+// moduleLoad is the first import of a module, which the other imports of it
+// wait for unless they run inside the module itself.
+type moduleLoad struct {
+	m    *Interpreter  // the top level scope of the module, nil until it runs
+	done chan struct{} // closed when the import finished, failed or not
+}
+
+// ModulePaths lists where an import of path from the file from looks for the
+// module, in order: next to from, in the min_modules directory of the
+// packages installed by `minimum pkg` there or in a directory above, then in
+// every directory of the MINIMUM_PATH list. An absolute path is only looked
+// for as it is.
+func ModulePaths(from, path string) []string {
+	if filepath.IsAbs(path) {
+		return []string{path}
+	}
+	dir := filepath.Dir(from)
+	paths := []string{filepath.Join(dir, path)}
+	for {
+		paths = append(paths, filepath.Join(dir, "min_modules", path))
+		abs, err := filepath.Abs(dir)
+		if err != nil || filepath.Dir(abs) == abs {
+			break
+		}
+		dir = filepath.Join(dir, "..")
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("MINIMUM_PATH")) {
+		if dir != "" {
+			paths = append(paths, filepath.Join(dir, path))
+		}
+	}
+	return paths
+}
+
+// imports returns the module cache of the run, creating it in the top level
+// scope the first time a scope of the run imports a module or is copied.
+func (in *Interpreter) imports() *moduleCache {
+	root := in
+	for root.loaded == nil && root.up() != nil {
+		root = root.up()
+	}
+	if root.loaded == nil {
+		root.loaded = &moduleCache{modules: make(map[string]*Interpreter), loading: make(map[string]*moduleLoad)}
+	}
+	in.loaded = root.loaded
+	return in.loaded
+}
+
+// importModule runs the module path, imported by the action, unless an
+// earlier import ran it already, and returns its top level scope.
+func (in *Interpreter) importModule(action bytecode.Action, path string) (*Interpreter, bool) {
+	var b []byte
+	file := ""
+	for _, candidate := range ModulePaths(in.fileName(), path) {
+		if bundled, ok := Bundled[candidate]; ok {
+			b, file = bundled, candidate
+			break
+		}
+		if _, err := os.Stat(candidate); err == nil {
+			file = candidate
+			break
+		}
+	}
+	if file == "" {
+		in.Error(action, fmt.Sprintf("module %q not found, looked in: %s", path, strings.Join(ModulePaths(in.fileName(), path), ", ")), "sys")
+		return nil, true
+	}
+	key, _ := filepath.Abs(file)
+	cache := in.imports()
+	cache.mu.Lock()
+	for {
+		if m, done := cache.modules[key]; done {
+			cache.mu.Unlock()
+			return m, false
+		}
+		load, loading := cache.loading[key]
+		if !loading {
+			break
+		}
+		if load.m != nil && in.within(load.m) {
+			cache.mu.Unlock()
+			in.Error(action, fmt.Sprintf("circular import of module %q", file), "value")
+			return nil, true
+		}
+		// another scope, such as a pool worker, runs it for the first time
+		cache.mu.Unlock()
+		<-load.done
+		cache.mu.Lock()
+	}
+	load := &moduleLoad{done: make(chan struct{})}
+	cache.loading[key] = load
+	cache.mu.Unlock()
+	defer func() {
+		cache.mu.Lock()
+		delete(cache.loading, key)
+		cache.mu.Unlock()
+		close(load.done)
+	}()
+	if b == nil {
+		if in.forbidden(action, "read", file) {
+			return nil, true
+		}
+		var err error
+		b, err = os.ReadFile(file)
+		if err != nil {
+			in.Error(action, err.Error(), "sys")
+			return nil, true
+		}
+	}
+	var entry string
+	if bytecode.IsCompiled(b) {
+		unit, err := bytecode.Decode(b)
+		if err != nil {
+			in.Error(action, file+": "+err.Error(), "value")
+			return nil, true
+		}
+		entry = in.Link(unit)
+	} else {
+		var err error
+		entry, err = in.Compile(string(b), file)
+		if err != nil {
+			in.Error(action, file+": "+err.Error(), "syntax")
+			return nil, true
+		}
+	}
+	m := in.newModule(file)
+	cache.mu.Lock()
+	load.m = m
+	cache.mu.Unlock()
+	m.caller, m.call = in, action.Source
+	failed := m.Run(entry)
+	m.caller, m.call = nil, nil
+	if failed {
+		in.Err = m.Err
+		return nil, true
+	}
+	// its functions, struct methods and constructors included, look names up
+	// in the module wherever they are called
+	for name := range m.V.Names {
+		if m.Type(name) != FUNC {
+			continue
+		}
+		fn := m.NamedFunc(name)
+		if fn.Env == nil && (fn.Node != "" || fn.Struct != nil) {
+			fn.Env = m
+		}
+		if fn.Struct == nil || fn.Node != "" {
+			continue
+		}
+		for _, method := range fn.Struct.Methods {
+			if method.Env == nil {
+				method.Env = m
+			}
+		}
+	}
+	cache.mu.Lock()
+	cache.modules[key] = m
+	cache.mu.Unlock()
+	return m, false
+}
+
+// within reports whether in runs inside the scope m, in a call it made or a
+// pool worker it started.
+func (in *Interpreter) within(m *Interpreter) bool {
+	for scope := in; scope != nil; {
+		if scope == m {
+			return true
+		}
+		if up := scope.up(); up != nil {
+			scope = up
+		} else {
+			scope = scope.origin
+		}
+	}
+	return false
+}
+
+// newModule creates the top level scope of the module file, which shares the
+// compiled code, settings and streams of in but none of its variables.
+func (in *Interpreter) newModule(file string) *Interpreter {
+	m := &Interpreter{V: &Vars{Names: make(map[string]int)}}
+	m.Copy(in)
+	m.Parent, m.Id, m.File, m.fn = nil, rand.Uint64(), &file, "<module>"
+	for _, fn := range bytecode.GenerateFuns() {
+		m.Save(fn.Name, &fn)
+	}
+	for name := range in.Hosts {
+		m.Save(name, &bytecode.Function{Name: name})
+	}
+	m.V.gcMax = 10000
+	m.Nothing("Nothing")
+	return m
+}
+
+// exports gives the top level names of the module m as a pair owned by in,
+// structs included, leaving out the built-in functions and the names starting
+// with "_".
+func (in *Interpreter) exports(m *Interpreter) bytecode.Pair {
+	p := bytecode.Pair{Ids: make(map[string]*bytecode.MinPtr)}
+	for name := range m.V.Names {
+		if name == "" || strings.HasPrefix(name, "_") || name == "Nothing" {
+			continue
+		}
+		if m.Type(name) == FUNC && m.NamedFunc(name).Node == "" && m.NamedFunc(name).Struct == nil {
+			continue
+		}
+		PairAppend(&p, in, in.carry(m, name), name)
+	}
+	return p
+}
+
+// MODULES END
@@ -0,0 +1,90 @@
+package inter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"minimum/bytecode"
+)
+
+// writeModules writes the files, by name, into a new directory and returns it.
+func writeModules(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, source := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestPoolWorkersShareModules(t *testing.T) {
+	in := NewInterpreter("", "main.min")
+	for _, copy := range []func(w, og *Interpreter){(*Interpreter).Copy2, (*Interpreter).CopyDeep} {
+		w := &Interpreter{V: &Vars{Names: make(map[string]int)}}
+		copy(w, &in)
+		if w.loaded == nil || w.loaded != in.loaded {
+			t.Errorf("a pool worker does not share the modules of its scope")
+		}
+	}
+}
+
+func TestConcurrentImport(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"slow.min": "!print \"loading\"\nx = 0\nrepeat 2000:\n    x = x + 1\nv = x",
+	})
+	var out strings.Builder
+	in := NewInterpreter("", filepath.Join(dir, "main.min"))
+	in.Stdout, in.Stderr = &out, &out
+	workers := make([]*Interpreter, 8)
+	for n := range workers {
+		workers[n] = &Interpreter{V: &Vars{Names: make(map[string]int)}}
+		workers[n].Copy2(&in)
+	}
+	modules := make([]*Interpreter, len(workers))
+	var wg sync.WaitGroup
+	for n, w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m, failed := w.importModule(bytecode.Action{Type: "import"}, "slow.min")
+			if failed {
+				t.Errorf("worker %d failed to import: %s", n, w.Err.Message)
+			}
+			modules[n] = m
+		}()
+	}
+	wg.Wait()
+	for n, m := range modules {
+		if m != modules[0] {
+			t.Errorf("worker %d got a module of its own", n)
+		}
+	}
+	if got := strings.Count(out.String(), "loading"); got != 1 {
+		t.Errorf("the module ran %d times, want once", got)
+	}
+}
+
+func TestCircularImport(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"a.min": "import \"b.min\" as b",
+		"b.min": "import \"a.min\" as a",
+	})
+	b, err := os.ReadFile(filepath.Join(dir, "a.min"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	in := NewInterpreter(string(b), filepath.Join(dir, "a.min"))
+	in.Stdout, in.Stderr = &out, &out
+	if !in.Run(in.Entry) {
+		t.Fatal("importing a module back did not fail")
+	}
+	if in.Err.Type != "value" || !strings.Contains(in.Err.Message, "circular import") {
+		t.Errorf("importing a module back failed with %s: %s, want a circular import", in.Err.Type, in.Err.Message)
+	}
+}
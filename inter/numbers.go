@@ -0,0 +1,289 @@
+package inter
+
+import (
+	"math"
+	"math/big"
+	"minimum/bytecode"
+)
+
+// NUMBERS START
+
+// Ints and floats are stored as int64 and float64 while they fit, so loops
+// counting or summing do not allocate. A nil entry of Ints or Floats marks a
+// machine sized value kept at the same index of Smalls or Doubles; reading it
+// through intAt or floatAt turns it into a big number for good, as the rest of
+// the interpreter keeps and mutates those pointers. Spans always hold big
+// numbers.
+
+func (v *Vars) intAt(index int) *big.Int {
+	if i := v.Ints[index]; i != nil {
+		return i
+	}
+	i := big.NewInt(v.Smalls[index])
+	v.Ints[index] = i
+	return i
+}
+
+func (v *Vars) floatAt(index int) *big.Float {
+	if f := v.Floats[index]; f != nil {
+		return f
+	}
+	f := big.NewFloat(v.Doubles[index])
+	v.Floats[index] = f
+	return f
+}
+
+func (v *Vars) setSmall(index int, n int64) {
+	v.Ints[index] = nil
+	for len(v.Smalls) <= index {
+		v.Smalls = append(v.Smalls, 0)
+	}
+	v.Smalls[index] = n
+}
+
+func (v *Vars) setDouble(index int, f float64) {
+	v.Floats[index] = nil
+	for len(v.Doubles) <= index {
+		v.Doubles = append(v.Doubles, 0)
+	}
+	v.Doubles[index] = f
+}
+
+// appendInt copies the int at index of old into v, used by the collectors.
+func (v *Vars) appendInt(old *Vars, index int) int {
+	n := len(v.Ints)
+	v.Ints = append(v.Ints, old.Ints[index])
+	if old.Ints[index] == nil {
+		v.setSmall(n, old.Smalls[index])
+	}
+	return n
+}
+
+func (v *Vars) appendFloat(old *Vars, index int) int {
+	n := len(v.Floats)
+	v.Floats = append(v.Floats, old.Floats[index])
+	if old.Floats[index] == nil {
+		v.setDouble(n, old.Doubles[index])
+	}
+	return n
+}
+
+// int64Of returns the value of the variable n of the action, see slotAt,
+// when it is a local int that fits an int64.
+func (in *Interpreter) int64Of(action *bytecode.Action, n int) (int64, bool) {
+	slot_id, ok := in.V.slotAt(action, n)
+	if !ok || in.V.Slots[slot_id].Type != INT {
+		return 0, false
+	}
+	index := in.V.Slots[slot_id].Index
+	if i := in.V.Ints[index]; i != nil {
+		return i.Int64(), i.IsInt64()
+	}
+	return in.V.Smalls[index], true
+}
+
+// float64Of returns the value of the variable n of the action when it is a
+// local float and float64 arithmetic on it rounds exactly like big.Float
+// does, that is when it has the default 53 bits of precision and !precision
+// was not used.
+func (in *Interpreter) float64Of(action *bytecode.Action, n int) (float64, bool) {
+	slot_id, ok := in.V.slotAt(action, n)
+	if !ok || in.V.Slots[slot_id].Type != FLOAT || in.customPrecision() {
+		return 0, false
+	}
+	index := in.V.Slots[slot_id].Index
+	if f := in.V.Floats[index]; f != nil {
+		if f.Prec() != 53 {
+			return 0, false
+		}
+		d, accuracy := f.Float64()
+		return d, accuracy == big.Exact
+	}
+	return in.V.Doubles[index], true
+}
+
+// SaveInt64 stores an int without allocating a big.Int.
+func (in *Interpreter) SaveInt64(name string, n int64) {
+	slot_id, ok := in.V.Names[name]
+	in.saveInt64(name, slot_id, ok, n)
+}
+
+// saveInt64 is SaveInt64 given the slot of name, if it has one.
+func (in *Interpreter) saveInt64(name string, slot_id int, ok bool, n int64) {
+	if ok && in.V.Slots[slot_id].Type == INT {
+		in.V.setSmall(in.V.Slots[slot_id].Index, n)
+		return
+	}
+	entry := Entry{INT, len(in.V.Ints)}
+	in.V.Ints = append(in.V.Ints, nil)
+	in.V.setSmall(entry.Index, n)
+	in.V.bind(name, len(in.V.Slots))
+	in.V.Slots = append(in.V.Slots, entry)
+}
+
+// SaveFloat64 stores a 53 bit float without allocating a big.Float.
+func (in *Interpreter) SaveFloat64(name string, f float64) {
+	slot_id, ok := in.V.Names[name]
+	in.saveFloat64(name, slot_id, ok, f)
+}
+
+func (in *Interpreter) saveFloat64(name string, slot_id int, ok bool, f float64) {
+	if ok && in.V.Slots[slot_id].Type == FLOAT {
+		in.V.setDouble(in.V.Slots[slot_id].Index, f)
+		return
+	}
+	entry := Entry{FLOAT, len(in.V.Floats)}
+	in.V.Floats = append(in.V.Floats, nil)
+	in.V.setDouble(entry.Index, f)
+	in.V.bind(name, len(in.V.Slots))
+	in.V.Slots = append(in.V.Slots, entry)
+}
+
+// storeInt64 saves n into the target of the action.
+func (in *Interpreter) storeInt64(action *bytecode.Action, n int64) {
+	slot_id, ok := in.V.slotAt(action, 0)
+	in.saveInt64(action.Target, slot_id, ok, n)
+}
+
+func (in *Interpreter) storeFloat64(action *bytecode.Action, f float64) {
+	slot_id, ok := in.V.slotAt(action, 0)
+	in.saveFloat64(action.Target, slot_id, ok, f)
+}
+
+// normal reports whether a float64 result carries the same value big.Float
+// would have computed: finite and not subnormal, as big.Float has neither
+// overflow nor gradual underflow.
+func normal(f float64) bool {
+	return f == 0 || !math.IsInf(f, 0) && !math.IsNaN(f) && math.Abs(f) >= 0x1p-1022
+}
+
+// fastAction runs the actions having a machine sized path. It is tried before
+// the target is reset to Nothing, so that a target already holding a number
+// of the same type is overwritten in place.
+func (in *Interpreter) fastAction(action *bytecode.Action) bool {
+	switch action.Type {
+	case "const":
+		switch c := action.Value.(type) {
+		case *big.Int:
+			if c.IsInt64() {
+				in.storeInt64(action, c.Int64())
+				return true
+			}
+		case *big.Float:
+			if f, accuracy := c.Float64(); accuracy == big.Exact && !in.customPrecision() && normal(f) {
+				in.storeFloat64(action, f)
+				return true
+			}
+		}
+	case "=":
+		if x, ok := in.int64Of(action, 1); ok {
+			in.storeInt64(action, x)
+			return true
+		}
+		if x, ok := in.float64Of(action, 1); ok {
+			in.storeFloat64(action, x)
+			return true
+		}
+	case "++", "--":
+		x, ok := in.int64Of(action, 1)
+		if !ok || action.Type == "++" && x == math.MaxInt64 || action.Type == "--" && x == math.MinInt64 {
+			return false
+		}
+		if action.Type == "++" {
+			in.storeInt64(action, x+1)
+		} else {
+			in.storeInt64(action, x-1)
+		}
+		return true
+	case "+", "-", "*", "/", "<", ">", "==", "!=":
+		return in.fastNumbers(action)
+	}
+	return false
+}
+
+// fastNumbers runs arithmetic and comparisons of two machine sized numbers of
+// the same type, reporting false when the big number code has to take over:
+// other operand types, an overflow or a result big.Float would round
+// differently.
+func (in *Interpreter) fastNumbers(action *bytecode.Action) bool {
+	if len(action.Variables) != 2 {
+		return false
+	}
+	if x, ok := in.int64Of(action, 1); ok {
+		y, ok := in.int64Of(action, 2)
+		if !ok {
+			return false
+		}
+		switch action.Type {
+		case "+":
+			r := x + y
+			if (r > x) != (y > 0) {
+				return false
+			}
+			in.storeInt64(action, r)
+		case "-":
+			r := x - y
+			if (r < x) != (y > 0) {
+				return false
+			}
+			in.storeInt64(action, r)
+		case "*":
+			if x == 0 || y == 0 {
+				in.storeInt64(action, 0)
+				return true
+			}
+			r := x * y
+			if r/y != x || (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+				return false
+			}
+			in.storeInt64(action, r)
+		case "<":
+			in.store(action, x < y)
+		case ">":
+			in.store(action, x > y)
+		case "==":
+			in.store(action, x == y)
+		case "!=":
+			in.store(action, x != y)
+		default:
+			return false
+		}
+		return true
+	}
+	if x, ok := in.float64Of(action, 1); ok {
+		y, ok := in.float64Of(action, 2)
+		if !ok {
+			return false
+		}
+		var r float64
+		switch action.Type {
+		case "+":
+			r = x + y
+		case "-":
+			r = x - y
+		case "*":
+			r = x * y
+		case "/":
+			if y == 0 {
+				return false
+			}
+			r = x / y
+		case "<":
+			in.store(action, x < y)
+			return true
+		case ">":
+			in.store(action, x > y)
+			return true
+		default:
+			return false
+		}
+		if !normal(r) {
+			return false
+		}
+		in.storeFloat64(action, r)
+		return true
+	}
+	return false
+}
+
+// NUMBERS END
@@ -0,0 +1,36 @@
+package inter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMachineNumbers(t *testing.T) {
+	prelude := "max = 9223372036854775807\nmin = -9223372036854775808\n"
+	for _, c := range []struct {
+		source, want string
+	}{
+		{"!print max + 1", "9223372036854775808"},
+		{"!print min - 1", "-9223372036854775809"},
+		{"!print max - -1", "9223372036854775808"},
+		{"!print min + -1", "-9223372036854775809"},
+		{"!print max * 2", "18446744073709551614"},
+		{"!print min * -1", "9223372036854775808"},
+		{"!print min * 1, max * -1", "-9223372036854775808 -9223372036854775807"},
+		{"x = max\nx++\n!print x", "9223372036854775808"},
+		{"x = min\nx--\n!print x", "-9223372036854775809"},
+		{"x = max - 1\nx++\n!print x == max", "true"},
+		{"x = max + 1\n!print x - 1 == max, x - 1 < x", "true true"},
+		{"!print max > min, min < 0, max == max", "true true true"},
+		{"!print 3.0 * 1.5, 0.1 + 0.2", "4.5 0.3"},
+	} {
+		script := compile(t, prelude+c.source)
+		stdout := &strings.Builder{}
+		script.Interpreter().Stdout = stdout
+		if err := script.Run(); err != nil {
+			t.Errorf("%q: %v", c.source, err)
+		} else if got := strings.TrimSuffix(stdout.String(), "\n"); got != c.want {
+			t.Errorf("%q printed %s, want %s", c.source, got, c.want)
+		}
+	}
+}
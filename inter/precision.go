@@ -0,0 +1,279 @@
+package inter
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"minimum/bytecode"
+	"sort"
+	"strings"
+)
+
+// PRECISION START
+
+// Precision holds the float settings of `!precision`, shared by an
+// interpreter and all of its children. The zero value keeps the historical
+// behaviour: 53 bit floats rounded to nearest even, printed with 10 digits.
+type Precision struct {
+	Bits    uint             // mantissa bits of new floats, 0 for 53
+	Mode    big.RoundingMode // rounding of new floats
+	Decimal bool             // exact base 10 arithmetic rounded to Places
+	Places  int              // decimal places kept in decimal mode
+}
+
+// decimalBits is the mantissa of floats in decimal mode, enough for a value
+// to come back as the same decimal after any number of round trips.
+const decimalBits = 512
+
+var roundingModes = map[string]big.RoundingMode{
+	"nearest_even": big.ToNearestEven,
+	"nearest_away": big.ToNearestAway,
+	"zero":         big.ToZero,
+	"away":         big.AwayFromZero,
+	"down":         big.ToNegativeInf,
+	"up":           big.ToPositiveInf,
+}
+
+// SetPrecision changes the float settings of the interpreter and everything
+// sharing them.
+func (in *Interpreter) SetPrecision(p Precision) {
+	if in.prec == nil {
+		in.prec = &Precision{}
+	}
+	*in.prec = p
+}
+
+func (in *Interpreter) customPrecision() bool {
+	return in.prec != nil && *in.prec != Precision{}
+}
+
+func (in *Interpreter) decimal() bool {
+	return in.prec != nil && in.prec.Decimal
+}
+
+// newFloat returns a zero float with the requested precision and rounding,
+// ready to receive a result.
+func (in *Interpreter) newFloat() *big.Float {
+	switch {
+	case !in.customPrecision():
+		return big.NewFloat(0)
+	case in.prec.Decimal:
+		return new(big.Float).SetPrec(decimalBits)
+	case in.prec.Bits == 0:
+		return new(big.Float).SetPrec(53).SetMode(in.prec.Mode)
+	}
+	return new(big.Float).SetPrec(in.prec.Bits).SetMode(in.prec.Mode)
+}
+
+// parseFloat reads a float literal, exactly in decimal mode.
+func (in *Interpreter) parseFloat(literal string) (*big.Float, bool) {
+	if in.decimal() {
+		r, ok := new(big.Rat).SetString(literal)
+		if !ok {
+			return nil, false
+		}
+		return in.decimalFloat(r), true
+	}
+	return in.newFloat().SetString(literal)
+}
+
+// decimalRat returns the decimal a float stands for in decimal mode, nil for
+// infinities.
+func (in *Interpreter) decimalRat(f *big.Float) *big.Rat {
+	if f.IsInf() {
+		return nil
+	}
+	r, _ := new(big.Rat).SetString(f.Text('f', in.prec.Places))
+	return r
+}
+
+// decimalFloat rounds r to the decimal places of decimal mode with its
+// rounding mode.
+func (in *Interpreter) decimalFloat(r *big.Rat) *big.Float {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(in.prec.Places)), nil)
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(scale))
+	q, m := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if m.Sign() != 0 {
+		sign := int64(scaled.Sign())
+		away := false
+		switch in.prec.Mode {
+		case big.AwayFromZero:
+			away = true
+		case big.ToNegativeInf:
+			away = sign < 0
+		case big.ToPositiveInf:
+			away = sign > 0
+		case big.ToNearestEven, big.ToNearestAway:
+			half := new(big.Int).Mul(new(big.Int).Abs(m), big.NewInt(2)).Cmp(scaled.Denom())
+			away = half > 0 || half == 0 && (in.prec.Mode == big.ToNearestAway || q.Bit(0) == 1)
+		}
+		if away {
+			q.Add(q, big.NewInt(sign))
+		}
+	}
+	return new(big.Float).SetPrec(decimalBits).SetRat(new(big.Rat).SetFrac(q, scale))
+}
+
+// floatOp computes x op y for +, -, * and / with the requested precision.
+// Decimal mode works on the exact decimals, falling back to binary floats for
+// infinities and division by zero.
+func (in *Interpreter) floatOp(op string, x, y *big.Float) *big.Float {
+	if in.decimal() && !(op == "/" && y.Sign() == 0) {
+		rx, ry := in.decimalRat(x), in.decimalRat(y)
+		if rx != nil && ry != nil {
+			r := new(big.Rat)
+			switch op {
+			case "+":
+				r.Add(rx, ry)
+			case "-":
+				r.Sub(rx, ry)
+			case "*":
+				r.Mul(rx, ry)
+			case "/":
+				r.Quo(rx, ry)
+			}
+			return in.decimalFloat(r)
+		}
+	}
+	z := in.newFloat()
+	if p := max(x.Prec(), y.Prec()); p > z.Prec() {
+		z.SetPrec(p) // a value given more bits with !precision keeps them
+	}
+	switch op {
+	case "+":
+		z.Add(x, y)
+	case "-":
+		z.Sub(x, y)
+	case "*":
+		z.Mul(x, y)
+	case "/":
+		z.Quo(x, y)
+	}
+	return z
+}
+
+// floatPow raises x to y. Integer exponents are computed with the requested
+// precision (exactly in decimal mode), others through float64.
+func (in *Interpreter) floatPow(x, y *big.Float) *big.Float {
+	if !in.customPrecision() || !y.IsInt() || y.IsInf() {
+		o, _ := x.Float64()
+		t, _ := y.Float64()
+		return big.NewFloat(math.Pow(o, t))
+	}
+	n, _ := y.Int(nil)
+	if in.decimal() {
+		rx := in.decimalRat(x)
+		if rx != nil && (rx.Sign() != 0 || n.Sign() >= 0) {
+			r := new(big.Rat).SetFrac(new(big.Int).Exp(rx.Num(), new(big.Int).Abs(n), nil), new(big.Int).Exp(rx.Denom(), new(big.Int).Abs(n), nil))
+			if n.Sign() < 0 {
+				r.Inv(r)
+			}
+			return in.decimalFloat(r)
+		}
+	}
+	result := in.newFloat().SetInt64(1)
+	base := in.newFloat().Set(x)
+	for e := new(big.Int).Abs(n); e.Sign() > 0; e.Rsh(e, 1) {
+		if e.Bit(0) == 1 {
+			result.Mul(result, base)
+		}
+		base.Mul(base, base)
+	}
+	if n.Sign() < 0 {
+		result.Quo(in.newFloat().SetInt64(1), result)
+	}
+	return result
+}
+
+// floatText prints a float: with 10 digits by default, with every digit its
+// precision holds once precision was changed, with the kept places in
+// decimal mode.
+func (in *Interpreter) floatText(f *big.Float) string {
+	switch {
+	case in.decimal():
+		return f.Text('f', in.prec.Places)
+	case in.customPrecision() || f.Prec() != 53:
+		return f.Text('g', -1)
+	}
+	return f.String()
+}
+
+// precision runs `!precision bits[, mode]`, `!precision "decimal", places[,
+// mode]` and `!precision float, bits[, mode]`. The first two change the
+// settings of the whole run (`!precision 0` restores the defaults), the last
+// returns the float rounded to bits.
+func (in *Interpreter) precision(action bytecode.Action) bool {
+	e := in.CheckArgN(action, 1, 3)
+	if e {
+		return e
+	}
+	e = in.CheckDtype(action, 0, INT, FLOAT, STR)
+	if e {
+		return e
+	}
+	mode := big.ToNearestEven
+	if len(action.Variables) == 3 || len(action.Variables) == 2 && in.Type(action.First()) == INT {
+		last := string(action.Variables[len(action.Variables)-1])
+		e = in.CheckDtype(action, len(action.Variables)-1, STR)
+		if e {
+			return e
+		}
+		m, ok := roundingModes[in.NamedStr(last)]
+		if !ok {
+			names := make([]string, 0, len(roundingModes))
+			for name := range roundingModes {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			in.Error(action, fmt.Sprintf("unknown rounding mode %q, expected one of: %s", in.NamedStr(last), strings.Join(names, ", ")), "value")
+			return true
+		}
+		mode = m
+	}
+	switch in.Type(action.First()) {
+	case INT:
+		bits := in.NamedInt(action.First())
+		if bits.Sign() < 0 || !bits.IsUint64() || bits.Uint64() > big.MaxPrec {
+			in.Error(action, "precision must be between 0 and "+fmt.Sprint(uint64(big.MaxPrec))+" bits", "value")
+			return true
+		}
+		in.SetPrecision(Precision{Bits: uint(bits.Uint64()), Mode: mode})
+	case STR:
+		if in.NamedStr(action.First()) != "decimal" || len(action.Variables) < 2 {
+			in.Error(action, `expected !precision "decimal", places`, "value")
+			return true
+		}
+		e = in.CheckDtype(action, 1, INT)
+		if e {
+			return e
+		}
+		places := in.NamedInt(action.Second())
+		if places.Sign() < 0 || !places.IsInt64() || places.Int64() > 1000 {
+			in.Error(action, "decimal places must be between 0 and 1000", "value")
+			return true
+		}
+		in.SetPrecision(Precision{Decimal: true, Places: int(places.Int64()), Mode: mode})
+	case FLOAT:
+		if len(action.Variables) < 2 {
+			in.Error(action, "expected !precision float, bits", "arg_count")
+			return true
+		}
+		if len(action.Variables) == 2 {
+			mode = in.NamedFloat(action.First()).Mode()
+		}
+		e = in.CheckDtype(action, 1, INT)
+		if e {
+			return e
+		}
+		bits := in.NamedInt(action.Second())
+		if bits.Sign() <= 0 || !bits.IsUint64() || bits.Uint64() > big.MaxPrec {
+			in.Error(action, "precision must be between 1 and "+fmt.Sprint(uint64(big.MaxPrec))+" bits", "value")
+			return true
+		}
+		f := new(big.Float).SetMode(mode).SetPrec(uint(bits.Uint64()))
+		in.Save(action.Target, f.Set(in.NamedFloat(action.First())))
+	}
+	return false
+}
+
+// PRECISION END
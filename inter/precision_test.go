@@ -0,0 +1,39 @@
+package inter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrecision(t *testing.T) {
+	for _, c := range []struct {
+		source, want string
+	}{
+		{"!print 0.1 + 0.2, 2.0 / 3", "0.3 0.6666666667"},
+		{"!precision \"decimal\", 2\n!print 0.1 + 0.2, 10.0 / 3", "0.30 3.33"},
+		{"!precision \"decimal\", 2\nx = 1.0 / 8\n!print x, x * 8", "0.12 0.96"},
+		{"!precision \"decimal\", 2, \"down\"\n!print 2.0 / 3", "0.66"},
+		{"!precision \"decimal\", 2, \"up\"\nm = 0 - 1.0\n!print 1.0 / 3, m / 3", "0.34 -0.33"},
+		{"!precision 200\n!print 1.0 / 3", "0.3333333333333333333333333333333333333333333333333333333333334"},
+		{"!precision \"decimal\", 2\n!precision 0\n!print 2.0 / 3", "0.6666666667"},
+		{"!print (!precision 1.0 / 3, 8, \"zero\")", "0.332"},
+		{"!print (!precision 2.5, 2, \"nearest_away\")", "3"},
+		{"func third:\n    return 1.0 / 3\n!precision \"decimal\", 3\n!print (!third)", "0.333"},
+	} {
+		script := compile(t, c.source)
+		stdout := &strings.Builder{}
+		script.Interpreter().Stdout = stdout
+		if err := script.Run(); err != nil {
+			t.Errorf("%q: %v", c.source, err)
+		} else if got := strings.TrimSuffix(stdout.String(), "\n"); got != c.want {
+			t.Errorf("%q printed %s, want %s", c.source, got, c.want)
+		}
+	}
+}
+
+func TestPrecisionMode(t *testing.T) {
+	err := compile(t, "!precision 10, \"sideways\"").Run()
+	if rerr, ok := err.(*RuntimeError); !ok || rerr.Type != "value" {
+		t.Errorf("an unknown rounding mode returned %v, want a value error", err)
+	}
+}
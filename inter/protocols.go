@@ -0,0 +1,189 @@
+package inter
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"minimum/bytecode"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// PROTOCOLS START
+
+// A struct plugs its instances into the builtins by defining methods with
+// the names below, each taking the instance first:
+//
+//	_str self           the text print, out and Fmt show for it, a str
+//	_len self           what !len returns, an int
+//	_eq self, other     whether == holds (!= negates it), also for !has, a bool
+//	_less self, other   whether it comes before other in !sort, a bool
+//	_add self, other    the value of self + other
+//	_iter self          the list a for loop and !has go through
+//
+// The builtins call them on the left operand or the collection, and treat
+// an instance whose struct does not define one like any other pair.
+
+// protocol calls the method name of the struct of v for the action, with v
+// and args as its arguments, and returns what it returns, which must be of
+// the type want unless it is "". ok is false when v is not an instance or
+// its struct has no such method.
+func (in *Interpreter) protocol(action bytecode.Action, v any, name, want string, args ...any) (result any, ok, failed bool) {
+	p, is := v.(bytecode.Pair)
+	if !is || p.Struct == nil {
+		return nil, false, false
+	}
+	m, has := p.Struct.Methods[name]
+	if !has {
+		return nil, false, false
+	}
+	// the arguments are passed by name from a scope of their own
+	c_in := Interpreter{V: &Vars{
+		Names: make(map[string]int),
+	}}
+	c_in.Id = rand.Uint64()
+	c_in.Copy(in)
+	call := bytecode.Action{Target: "_result_", Type: m.Name, Variables: []bytecode.Variable{"_self_"}, Source: action.Source}
+	c_in.Save("_self_", v)
+	for n, arg := range args {
+		arg_name := fmt.Sprintf("_arg%d_", n)
+		c_in.Save(arg_name, arg)
+		call.Variables = append(call.Variables, bytecode.Variable(arg_name))
+	}
+	if c_in.invoke(m, call) {
+		in.Err = c_in.Err
+		return nil, true, true
+	}
+	result = in.carry(&c_in, "_result_")
+	if got := in.typeOf(result); want != "" && got != want {
+		in.Error(action, fmt.Sprintf("%s must return %s, not %s!", m.Name, want, got), "type")
+		return nil, true, true
+	}
+	return result, true, false
+}
+
+// show formats v the way print does, calling the _str method of every
+// instance in it that has one.
+func (in *Interpreter) show(action bytecode.Action, v any) (string, bool) {
+	if !in.holdsInstance(v) {
+		return in.Stringify(v), false
+	}
+	item := func(v any) (string, bool) {
+		if str, ok := v.(string); ok {
+			return "\"" + str + "\"", false
+		}
+		return in.show(action, v)
+	}
+	elements := []string{}
+	switch vt := v.(type) {
+	case bytecode.List:
+		for _, ptr := range vt.Ids {
+			text, failed := item(in.GetAnyRef(ptr))
+			if failed {
+				return "", true
+			}
+			elements = append(elements, text)
+		}
+		return "[" + strings.Join(elements, ", ") + "]", false
+	case bytecode.Pair:
+		if vt.Struct == nil {
+			for key, ptr := range vt.Ids {
+				kind, name, _ := strings.Cut(key, ":")
+				text, failed := item(in.GetAnyRef(ptr))
+				if failed {
+					return "", true
+				}
+				elements = append(elements, ternary(kind == "str", "\""+name+"\"", name)+": "+text)
+			}
+			return "{" + strings.Join(elements, ", ") + "}", false
+		}
+		if str, ok, failed := in.protocol(action, v, "_str", "str"); ok || failed {
+			return ternary(failed, "", fmt.Sprint(str)), failed
+		}
+		for _, field := range vt.Struct.Fields {
+			ptr, ok := vt.Ids["str:"+field.Name]
+			if !ok {
+				continue
+			}
+			text, failed := item(in.GetAnyRef(ptr))
+			if failed {
+				return "", true
+			}
+			elements = append(elements, field.Name+": "+text)
+		}
+		return vt.Struct.Name + "{" + strings.Join(elements, ", ") + "}", false
+	}
+	return in.Stringify(v), false
+}
+
+// holdsInstance reports whether v is an instance or a list or pair holding
+// one, at any depth.
+func (in *Interpreter) holdsInstance(v any) bool {
+	switch vt := v.(type) {
+	case bytecode.List:
+		return slices.ContainsFunc(vt.Ids, func(ptr *bytecode.MinPtr) bool { return in.holdsInstance(in.GetAnyRef(ptr)) })
+	case bytecode.Pair:
+		if vt.Struct != nil {
+			return true
+		}
+		for _, ptr := range vt.Ids {
+			if in.holdsInstance(in.GetAnyRef(ptr)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// contains reports whether the list l has an item equal to v, comparing the
+// instances that have an _eq method with it.
+func (in *Interpreter) contains(action bytecode.Action, l bytecode.List, v any) (found, failed bool) {
+	for _, ptr := range l.Ids {
+		item := in.GetAnyRef(ptr)
+		if eq, ok, failed := in.protocol(action, item, "_eq", "bool", v); ok || failed {
+			if failed || eq.(bool) {
+				return !failed, failed
+			}
+			continue
+		}
+		in.Save("_cmp_0", item)
+		in.Save("_cmp_1", v)
+		o, t := in.EqualizeTypes("_cmp_0", "_cmp_1")
+		if in.CompareName(o, t) {
+			return true, false
+		}
+	}
+	return false, false
+}
+
+// sortLess sorts the list l with the _less method of its items, when the
+// first one is an instance that has it; ok is false otherwise. The sort is
+// stable, and every item has to be an instance defining _less.
+func (in *Interpreter) sortLess(action bytecode.Action, l bytecode.List) (sorted bytecode.List, ok, failed bool) {
+	if len(l.Ids) == 0 {
+		return l, false, false
+	}
+	if p, is := in.GetAnyRef(l.Ids[0]).(bytecode.Pair); !is || p.Struct == nil || p.Struct.Methods["_less"] == nil {
+		return l, false, false
+	}
+	ids := slices.Clone(l.Ids)
+	sort.SliceStable(ids, func(i, j int) bool {
+		if failed {
+			return false
+		}
+		a := in.GetAnyRef(ids[i])
+		less, ok, f := in.protocol(action, a, "_less", "bool", in.GetAnyRef(ids[j]))
+		if !ok {
+			in.Error(action, fmt.Sprintf("%s has no _less to sort it by!", in.typeOf(a)), "type")
+			f = true
+		}
+		failed = f
+		return !failed && less.(bool)
+	})
+	for _, ptr := range ids {
+		ListAppend(&sorted, in, in.GetAnyRef(ptr))
+	}
+	return sorted, true, failed
+}
+
+// PROTOCOLS END
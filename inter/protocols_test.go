@@ -0,0 +1,17 @@
+package inter
+
+import "testing"
+
+const money = "struct Money cents int:\n    func _str self:\n        return \"$\" + (!convert self.cents, \"\")\n"
+
+func TestFmtStr(t *testing.T) {
+	for _, c := range []struct{ source, want string }{
+		{money + "p = !Money 150\ns = !fmt \"{p}\"\n!print s", "$150\n"},
+		{money + "l = [!Money 1, !Money 2]\ns = !fmt \"{l}\"\n!print s", "[$1, $2]\n"},
+		{money + "p = !Money 150\ns = !fmt \"total: {p}!\"\n!print s", "total: $150!\n"},
+	} {
+		if out := runOk(t, c.source); out != c.want {
+			t.Errorf("%q printed %q, want %q", c.source, out, c.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package inter
+
+import "minimum/bytecode"
+
+// SLOTS START
+
+// A scope follows the frame of the first compiled action it runs: Syms holds
+// the slot of each name of the frame, plus one, and 0 for a name the scope
+// does not have. Names stays the reference, Syms is written with it by bind
+// and unbind, so the actions of another frame, or built at run time, still
+// find their variables by name.
+
+// adopt makes v follow the layout f.
+func (v *Vars) adopt(f *bytecode.Frame) {
+	v.frame = f
+	v.Syms = make([]int32, len(f.Names))
+	for sym, name := range f.Names {
+		if slot, ok := v.Names[name]; ok {
+			v.Syms[sym] = int32(slot) + 1
+		}
+	}
+}
+
+// bind points name at slot.
+func (v *Vars) bind(name string, slot int) {
+	v.Names[name] = slot
+	if v.frame != nil {
+		if sym, ok := v.frame.Index[name]; ok {
+			v.Syms[sym] = int32(slot) + 1
+		}
+	}
+}
+
+func (v *Vars) unbind(name string) {
+	delete(v.Names, name)
+	if v.frame != nil {
+		if sym, ok := v.frame.Index[name]; ok {
+			v.Syms[sym] = 0
+		}
+	}
+}
+
+// relayout gives v, a copy of old made by a collection, the layout of old.
+func (v *Vars) relayout(old *Vars) {
+	if old.frame != nil {
+		v.frame, v.Syms = old.frame, make([]int32, len(old.Syms))
+	}
+}
+
+// slotAt returns the slot in v of the n-th name of the action, the target
+// being the name 0 and the variables following it, by its symbol when v
+// follows the frame of the action.
+func (v *Vars) slotAt(action *bytecode.Action, n int) (int, bool) {
+	if action.Frame != nil && action.Frame == v.frame {
+		if sym := action.Syms[n]; sym != bytecode.NoSymbol {
+			slot := v.Syms[sym]
+			return int(slot) - 1, slot > 0
+		}
+	}
+	slot, ok := v.Names[nameAt(action, n)]
+	return slot, ok
+}
+
+func nameAt(action *bytecode.Action, n int) string {
+	if n == 0 {
+		return action.Target
+	}
+	return string(action.Variables[n-1])
+}
+
+// typeAt is Type for the n-th name of the action, see slotAt.
+func (in *Interpreter) typeAt(action *bytecode.Action, n int) byte {
+	if slot, ok := in.V.slotAt(action, n); ok {
+		return in.V.Slots[slot].Type
+	}
+	return in.Parent.Type(nameAt(action, n))
+}
+
+// declared tells whether the variable m of the action is defined in the
+// scope or one of its parents, keywords of named arguments always being.
+func (in *Interpreter) declared(action *bytecode.Action, m int) bool {
+	if _, kw := bytecode.Keyword(action.Variables[m]); kw {
+		return true
+	}
+	if _, ok := in.V.slotAt(action, 1+m); ok {
+		return true
+	}
+	name := string(action.Variables[m])
+	for interp := in.Parent; interp != nil; interp = interp.Parent {
+		if _, ok := interp.V.Names[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SLOTS END
@@ -0,0 +1,61 @@
+package inter
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// STDIO START
+
+// stdout is where the script writes, counted toward the output limit.
+func (in *Interpreter) stdout() io.Writer {
+	if in.Stdout == nil {
+		return in.counted(os.Stdout)
+	}
+	return in.counted(in.Stdout)
+}
+
+func (in *Interpreter) stderr() io.Writer {
+	if in.Stderr == nil {
+		return os.Stderr
+	}
+	return in.Stderr
+}
+
+func (in *Interpreter) stdin() io.Reader {
+	if in.Stdin == nil {
+		return os.Stdin
+	}
+	return in.Stdin
+}
+
+// readLine shows the prompt and reads one line, from the terminal unless the
+// interpreter has its own Stdin. That one is read a byte at a time, so no
+// input is buffered away from other interpreters sharing the reader.
+func (in *Interpreter) readLine(prompt string) (string, error) {
+	if in.Stdin == nil {
+		RL.SetPrompt(prompt)
+		return RL.Readline()
+	}
+	io.WriteString(in.stdout(), prompt)
+	line := strings.Builder{}
+	b := make([]byte, 1)
+	for {
+		n, err := in.Stdin.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line.WriteByte(b[0])
+		}
+		if err == io.EOF && line.Len() > 0 {
+			break
+		} else if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSuffix(line.String(), "\r"), nil
+}
+
+// STDIO END
@@ -0,0 +1,178 @@
+package inter
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"minimum/bytecode"
+	"strings"
+)
+
+// STRUCTS START
+
+// declareStruct builds the constructor of the struct that the struct action
+// at focus declares, the func statements of its block becoming the methods.
+// Calling it with the fields as arguments, or with those of an init method
+// when the struct has one, returns a new instance.
+func (in *Interpreter) declareStruct(actions []bytecode.Action, focus int) *bytecode.Function {
+	action := actions[focus]
+	fields, types := bytecode.ParseFields(action.Variables[1:])
+	s := &bytecode.Struct{Name: action.First(), Fields: fields, Types: types, Methods: make(map[string]*bytecode.Function)}
+	block := in.Code[action.Target]
+	for n, act := range block {
+		if act.Type == "func" {
+			m := in.declare(block, n)
+			m.Name, m.Struct = s.Name+"."+m.Name, s
+			s.Methods[act.First()] = m
+		}
+	}
+	fn := &bytecode.Function{Name: s.Name, Vars: bytecode.ParamNames(fields), Params: fields, Struct: s}
+	if focus > 0 && actions[focus-1].Type == "##" {
+		fn.Doc = bytecode.DocText(actions[focus-1])
+	}
+	if in.Parent != nil {
+		fn.Env = in
+	}
+	return fn
+}
+
+// construct runs the constructor fn for the call action. Without an init
+// method the arguments give the fields their values the way they would the
+// parameters of a function. Otherwise the fields start at their default
+// values, or Nothing, and init gets the new instance and the arguments.
+func (in *Interpreter) construct(fn *bytecode.Function, action bytecode.Action) bool {
+	s := fn.Struct
+	f_in := Interpreter{V: &Vars{
+		Names: make(map[string]int),
+	}}
+	f_in.Id = rand.Uint64()
+	f_in.Copy(in)
+	f_in.enter(fn)
+	f_in.fn, f_in.call = s.Name, action.Source
+	init, custom := s.Methods["init"]
+	if custom {
+		for _, field := range s.Fields {
+			f_in.Nothing(field.Name)
+		}
+		if f_in.defaults(fn, 0) {
+			in.Err = f_in.Err
+			return true
+		}
+	} else if in.bind(&f_in, fn, action) {
+		return true
+	}
+	p := bytecode.Pair{Ids: make(map[string]*bytecode.MinPtr), Struct: s}
+	for _, field := range s.Fields {
+		PairAppend(&p, in, in.carry(&f_in, field.Name), field.Name)
+	}
+	f_in.Destroy()
+	in.Save(action.Target, p)
+	if custom {
+		bound := *init
+		bound.Recv = action.Target
+		if in.invoke(&bound, action) {
+			return true
+		}
+		if got := in.typeOf(in.GetAny(action.Target)); got != s.Name {
+			in.Error(action, fmt.Sprintf("%s left self a %s instead of a %s!", init.Name, got, s.Name), "type")
+			return true
+		}
+	}
+	p = in.NamedPair(action.Target)
+	for n, field := range s.Fields {
+		ptr, ok := p.Ids["str:"+field.Name]
+		if !ok {
+			continue
+		}
+		if err := in.checkField(s, n, in.GetAnyRef(ptr)); err != nil {
+			in.Error(action, err.Error(), err.etype)
+			return true
+		}
+	}
+	return false
+}
+
+// method returns the method of the instance p that the index action names,
+// bound to the variable indexed, or nil when p has no such method.
+func (in *Interpreter) method(p bytecode.Pair, action bytecode.Action) *bytecode.Function {
+	name, ok := in.GetAny(action.Second()).(string)
+	if p.Struct == nil || !ok {
+		return nil
+	}
+	m, ok := p.Struct.Methods[name]
+	if !ok {
+		return nil
+	}
+	bound := *m
+	bound.Recv = action.First()
+	return &bound
+}
+
+// typeOf names the type of v as !type does, the struct of an instance.
+func (in *Interpreter) typeOf(v any) string {
+	if p, ok := v.(bytecode.Pair); ok && p.Struct != nil {
+		return p.Struct.Name
+	}
+	return map[byte]string{NOTH: "noth", INT: "int", FLOAT: "float", BYTE: "byte", STR: "str", FUNC: "func", SPAN: "span", ID: "id", LIST: "list", BOOL: "bool", PAIR: "pair", ARR: "arr"}[TypeToByte(v)]
+}
+
+// fieldError is an assignment to an instance that its struct does not allow,
+// raised with its own error type.
+type fieldError struct {
+	message string
+	etype   string
+}
+
+func (e *fieldError) Error() string {
+	return e.message
+}
+
+// assignError gives the error type of a failed DeepAssign.
+func assignError(err error) string {
+	if fe, ok := err.(*fieldError); ok {
+		return fe.etype
+	}
+	return "index"
+}
+
+// assign checks that the instance p has the field inds[0] and, when it is
+// the one assigned, that item has the type declared for it.
+func (in *Interpreter) assign(p *bytecode.Pair, item any, inds []any) *fieldError {
+	name, ok := inds[0].(string)
+	n := p.Struct.Field(name)
+	if !ok || n < 0 {
+		return &fieldError{fmt.Sprintf("%s has no field %s", p.Struct.Name, in.Stringify(inds[0])), "index"}
+	}
+	if len(inds) > 1 {
+		return nil
+	}
+	return in.checkField(p.Struct, n, item)
+}
+
+// checkField fails when v does not have the type declared for the field n of s.
+func (in *Interpreter) checkField(s *bytecode.Struct, n int, v any) *fieldError {
+	if got := in.typeOf(v); s.Types[n] != "" && got != s.Types[n] {
+		return &fieldError{fmt.Sprintf("%s.%s must be %s, not %s", s.Name, s.Fields[n].Name, s.Types[n], got), "type"}
+	}
+	return nil
+}
+
+// StructString formats an instance as `Point{x: 1, y: 2}`, its fields in the
+// order they are declared.
+func StructString(p *bytecode.Pair, in *Interpreter) string {
+	elements := []string{}
+	for _, field := range p.Struct.Fields {
+		ptr, ok := p.Ids["str:"+field.Name]
+		if !ok {
+			continue
+		}
+		v := in.GetAnyRef(ptr)
+		text := in.Stringify(v)
+		if str, ok := v.(string); ok {
+			text = "\"" + str + "\""
+		}
+		elements = append(elements, field.Name+": "+text)
+	}
+	return p.Struct.Name + "{" + strings.Join(elements, ", ") + "}"
+}
+
+// STRUCTS END
@@ -0,0 +1,46 @@
+package inter
+
+import (
+	"strings"
+	"testing"
+)
+
+const point = "struct Point x int, y int=0:\n    func move self, dx, dy:\n        self.x += dx\n        self.y += dy\n"
+
+func TestStruct(t *testing.T) {
+	for _, c := range []struct {
+		source, want string
+	}{
+		{"p = !Point 1, y=2\n!print p.x, p.y", "1 2\n"},
+		{"p = !Point 1\n!p.move 1, 2\n!print p.x, p.y", "2 2\n"},
+		{"p = !Point 1\np.x = 5\n!print p.x, !type p", "5 Point\n"},
+		{"struct Box size:\n    func init self, n:\n        self.size = n * 2\nb = !Box 3\n!print b.size", "6\n"},
+		{"struct Box size=1:\n    func init self:\n        self.size += 1\nb = !Box\n!print b.size", "2\n"},
+	} {
+		if out := runOk(t, point+c.source); out != c.want {
+			t.Errorf("%q printed %q, want %q", c.source, out, c.want)
+		}
+	}
+}
+
+func TestStructErrors(t *testing.T) {
+	for _, c := range []struct {
+		source, etype string
+	}{
+		{"p = !Point \"a\"", "type"},
+		{"p = !Point 1\np.y = 1.5", "type"},
+		{"struct Line a Point:\n    func start self:\n        return self.a\nl = !Line 1", "type"},
+		{"p = !Point 1\n!print p.z", "index"},
+		{"p = !Point", "arg_count"},
+	} {
+		runErr(t, point+c.source, c.etype)
+	}
+}
+
+func TestStructInitReplacingSelf(t *testing.T) {
+	source := "struct Box size:\n    func init self:\n        self = 1\nb = !Box"
+	_, err := run(t, source)
+	if err == nil || err.Type != "type" || !strings.Contains(err.Message, "Box.init left self a int instead of a Box") {
+		t.Fatalf("%q failed with %v, want Box.init left self a int", source, err)
+	}
+}
@@ -8,79 +8,39 @@ import (
 	"syscall/js"
 
 	// update imports to match your repo layout
-	"minimum/bytecode" // bytecode types if needed
-	"minimum/inter"    // inter package (interpreter)
+	"minimum/inter" // inter package (interpreter)
 )
 
-// NOTE: adapt these helper-registration calls to your interpreter's host function API.
-// I show two variants (RegisterHostFunc / SetPair). Use whichever matches your codebase.
-
 var (
 	interpreter inter.Interpreter
 )
 
-/*
-// helper: create host functions and place them under a "html" pairing/dict
-func registerHTMLPair(in *inter.Interpreter) {
-	// --------- ADAPT: If your interpreter provides a registration API, use it here ----------
-	// Example A: interpreter.RegisterHostFunc("html.set", func(args ...interface{}) (interface{}, error) { ... })
-	// Example B: interpreter.SetPair("html", pairValue) where pairValue maps strings to callables
-	//
-	// Below I show a simple approach assuming you can register named host functions:
-	// Replace RegisterHostFunc with your real API call (RegisterNative, AddBuiltin, etc).
-	// ------------------------------------------------------------------------------------
-
-	// html.set: sets element.outerHTML
-	in.RegisterHostFunc("html.set", func(args ...interface{}) (interface{}, error) {
-		if len(args) < 2 {
-			return nil, fmt.Errorf("html.set requires 2 args: id, html")
-		}
-		id, ok1 := args[0].(string)
-		html, ok2 := args[1].(string)
-		if !ok1 || !ok2 {
-			return nil, fmt.Errorf("html.set expects strings")
-		}
+// registerHTML adds the DOM helpers that only make sense inside a browser.
+// html_set_inner is a builtin, html_set replaces the whole element.
+func registerHTML(in *inter.Interpreter) {
+	in.RegisterFunc("html_set", "str, str", func(args []inter.Value) (inter.Value, error) {
 		doc := js.Global().Get("document")
 		if !doc.Truthy() {
 			return nil, fmt.Errorf("no document")
 		}
-		el := doc.Call("getElementById", id)
+		el := doc.Call("getElementById", args[0].(string))
 		if !el.Truthy() {
-			// create an element container if not found? here we return error
-			return nil, fmt.Errorf("element with id %s not found", id)
+			return nil, fmt.Errorf("element with id %s not found", args[0])
 		}
-		// set outerHTML (replace element)
-		el.Set("outerHTML", html)
+		el.Set("outerHTML", args[1].(string))
 		return nil, nil
 	})
+}
 
-	// html.set_inner: sets element.innerHTML
-	in.RegisterHostFunc("html.set_inner", func(args ...interface{}) (interface{}, error) {
-		if len(args) < 2 {
-			return nil, fmt.Errorf("html.set_inner requires 2 args: id, html")
-		}
-		id, ok1 := args[0].(string)
-		html, ok2 := args[1].(string)
-		if !ok1 || !ok2 {
-			return nil, fmt.Errorf("html.set_inner expects strings")
-		}
-		doc := js.Global().Get("document")
-		if !doc.Truthy() {
-			return nil, fmt.Errorf("no document")
-		}
-		el := doc.Call("getElementById", id)
-		if !el.Truthy() {
-			return nil, fmt.Errorf("element with id %s not found", id)
-		}
-		el.Set("innerHTML", html)
-		return nil, nil
-	})
+// jsWriter hands everything written to it to a JavaScript function.
+type jsWriter struct {
+	fn js.Value
+}
 
-	// Optionally add convenience wrappers so Minimum code can call !html.set "id","<p>hi</p>"
-	// If your interpreter represents pairs/dicts differently, create a Pair named "html"
-	// and set function entries to the registered host funcs above.
+func (w jsWriter) Write(p []byte) (int, error) {
+	w.fn.Invoke(string(p))
+	return len(p), nil
 }
-*/
 
 func initInterpreter(code, fname string) error {
 	// create interpreter instance
@@ -90,10 +50,13 @@ func initInterpreter(code, fname string) error {
 	// optional: make initial Nothing run, replicate main behavior
 	interpreter.Nothing("Nothing")
 
-	// register html pair/host functions
-	// registerHTMLPair(interpreter)
+	registerHTML(&interpreter)
 
-	// If you want to export any other host functions (e.g. console.log) do it here.
+	// send print, out and error reports to Minimum_onOutput(text) when the page defines it
+	if handler := js.Global().Get("Minimum_onOutput"); handler.Type() == js.TypeFunction {
+		interpreter.Stdout = jsWriter{handler}
+		interpreter.Stderr = jsWriter{handler}
+	}
 
 	return nil
 }
@@ -113,7 +76,7 @@ func jsInit(this js.Value, args []js.Value) interface{} {
 	return map[string]interface{}{"ok": true}
 }
 
-// Exported JS function: run a node by name (e.g., "_node_123")
+// Exported JS function: run a node by name (e.g., "_node_1_0")
 func jsRunNode(this js.Value, args []js.Value) interface{} {
 	//if interpreter == nil {
 	//	return map[string]interface{}{"ok": false, "err": "interpreter not initialized"}
@@ -141,8 +104,10 @@ func jsCompileAndRun(this js.Value, args []js.Value) interface{} {
 	source := args[0].String()
 	origin := args[1].String()
 
-	interpreter.Compile(source, origin)
-	lastNode := fmt.Sprintf("_node_%d", bytecode.NodeN-1)
+	lastNode, cerr := interpreter.Compile(source, origin)
+	if cerr != nil {
+		return map[string]interface{}{"ok": false, "err": cerr.Error()}
+	}
 	// run lastNode
 	err := interpreter.Run(lastNode)
 	if err {
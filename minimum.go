@@ -4,6 +4,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -15,6 +17,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -228,6 +231,11 @@ var is_debug bool
 var is_safe bool
 var is_source bool
 var is_server bool
+var is_compile bool
+var is_bundle bool
+var is_doc bool
+var timeout time.Duration
+var limits inter.Limits
 var uses_template bool
 var error_message string
 var error_type string
@@ -239,7 +247,107 @@ func init() {
 	is_safe = bytecode.Has(os.Args, "-safe")
 	is_source = bytecode.Has(os.Args, "-source")
 	is_server = bytecode.Has(os.Args, "-server")
+	is_compile = bytecode.Has(os.Args, "-compile")
+	is_bundle = bytecode.Has(os.Args, "-bundle")
+	is_doc = bytecode.Has(os.Args, "-doc")
 	uses_template = bytecode.Has(os.Args, "-template")
+	seconds, _ := strconv.ParseFloat(flag_value("-timeout"), 64)
+	timeout = time.Duration(seconds * float64(time.Second))
+	limits.Actions, _ = strconv.ParseInt(flag_value("-max-actions"), 10, 64)
+	limits.Heap, _ = strconv.Atoi(flag_value("-max-heap"))
+	limits.Depth, _ = strconv.Atoi(flag_value("-max-depth"))
+	limits.Output, _ = strconv.ParseInt(flag_value("-max-output"), 10, 64)
+}
+
+// parse_capabilities reads the -allow-* flags. Without them, and without
+// -safe, scripts are unrestricted; otherwise they only get what is listed:
+// -allow-read[=paths], -allow-write[=paths], -allow-exec, -allow-net,
+// -allow-env, -allow-native or -allow-all, paths being comma separated.
+func parse_capabilities() *inter.Capabilities {
+	var caps *inter.Capabilities
+	if is_safe {
+		caps = &inter.Capabilities{}
+	}
+	for _, arg := range os.Args[1:] {
+		if !strings.HasPrefix(arg, "-allow-") {
+			continue
+		}
+		if caps == nil {
+			caps = &inter.Capabilities{}
+		}
+		name, paths, _ := strings.Cut(strings.TrimPrefix(arg, "-allow-"), "=")
+		switch name {
+		case "read":
+			caps.Read = inter.ParsePaths(paths)
+		case "write":
+			caps.Write = inter.ParsePaths(paths)
+		case "exec":
+			caps.Exec = true
+		case "net":
+			caps.Net = true
+		case "env":
+			caps.Env = true
+		case "native":
+			caps.Native = true
+		case "all":
+			*caps = inter.Capabilities{Read: inter.PathSet{All: true}, Write: inter.PathSet{All: true}, Exec: true, Net: true, Env: true, Native: true}
+		default:
+			fmt.Printf("Unknown capability: %s\n", arg)
+			os.Exit(1)
+		}
+	}
+	return caps
+}
+
+// flag_value returns the launch argument following flag, or "" when absent.
+func flag_value(flag string) string {
+	for n, arg := range os.Args {
+		if arg == flag && n+1 < len(os.Args) {
+			return os.Args[n+1]
+		}
+	}
+	return ""
+}
+
+// load_unit compiles the source file fname, or decodes it when it is a
+// .minc file written by -compile.
+func load_unit(fname string) (bytecode.Unit, error) {
+	bcode, err := os.ReadFile(fname)
+	if err != nil {
+		return bytecode.Unit{}, err
+	}
+	return compile_unit(bcode, fname)
+}
+
+// compile_unit compiles the contents of the file fname, reporting its syntax
+// errors as the interpreter prints them.
+func compile_unit(bcode []byte, fname string) (bytecode.Unit, error) {
+	if bytecode.IsCompiled(bcode) {
+		unit, err := bytecode.Decode(bcode)
+		if err != nil {
+			return bytecode.Unit{}, fmt.Errorf("%s: %v", fname, err)
+		}
+		return unit, nil
+	}
+	if is_source {
+		inter.ShowSource(string(bcode))
+	}
+	unit, err := bytecode.Compile(string(bcode))
+	if err != nil {
+		return unit, errors.New(strings.TrimSuffix(err.(bytecode.SyntaxErrors).Report(fname), "\n"))
+	}
+	return unit, nil
+}
+
+// compile_file writes the compiled fname next to it, app.min becoming
+// app.minc.
+func compile_file(fname string) error {
+	unit, err := load_unit(fname)
+	if err != nil {
+		return err
+	}
+	out := strings.TrimSuffix(fname, filepath.Ext(fname)) + ".minc"
+	return os.WriteFile(out, unit.Encode(), 0644)
 }
 
 func timer(name string) func() {
@@ -249,10 +357,42 @@ func timer(name string) func() {
 	}
 }
 
+// run_unit runs a compiled program with the limits given at launch.
+func run_unit(unit bytecode.Unit, fname string) {
+	if is_debug {
+		defer timer("interpreter")()
+	}
+	in := inter.NewCompiledInterpreter(unit, fname)
+	if is_debug {
+		bytecode.PrintActs(in.Code)
+	}
+	in.Nothing("Nothing")
+	if limits != (inter.Limits{}) {
+		in.SetLimits(limits)
+	}
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		in.RunContext(ctx, in.Entry)
+	} else {
+		in.Run(in.Entry)
+	}
+}
+
 func main() {
 	defer inter.CloseAllRpc()
-	if is_safe {
-		inter.IsSafe = true
+	inter.DefaultCapabilities = parse_capabilities()
+	exe, _ := os.Executable()
+	if b, ok := read_bundle(exe); ok {
+		run_bundle(b)
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "pkg" {
+		if err := run_pkg(".", os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
 	}
 	if uses_template {
 		var mint string
@@ -265,15 +405,18 @@ func main() {
 		bytecode.FillMinT(mint)
 	}
 	if is_server {
+		inter.ServerTimeout = timeout
+		inter.ServerLimits = limits
 		if fname := find_file_main(os.Args); fname != "" {
-			bcode, _ := os.ReadFile(fname)
-			code := string(bcode)
-			if is_source {
-				inter.ShowSource(code)
+			unit, err := load_unit(fname)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
 			}
-			inter.ServerInterpreter = inter.NewInterpreterPtr(code, fname)
+			in := inter.NewCompiledInterpreter(unit, fname)
+			inter.ServerInterpreter = &in
 			inter.ServerInterpreter.Nothing("Nothing")
-			inter.ServerInterpreter.Run(fmt.Sprintf("_node_%d", bytecode.NodeN-1))
+			inter.ServerInterpreter.Run(inter.ServerInterpreter.Entry)
 		}
 		http.HandleFunc("/", inter.ServerHandler)
 		addr := "5000"
@@ -287,21 +430,50 @@ func main() {
 		return
 	}
 	fname := find_file_main(os.Args)
-	if fname != "" {
-		bcode, _ := os.ReadFile(fname)
-		code := string(bcode)
-		if is_source {
-			inter.ShowSource(code)
+	if is_bundle {
+		if fname == "" {
+			fmt.Println("No file to bundle")
+			os.Exit(1)
 		}
-		if is_debug {
-			defer timer("interpreter")()
+		if err := bundle_file(fname); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
-		in := inter.NewInterpreter(code, fname)
-		if is_debug {
-			bytecode.PrintActs(in.Code)
+		return
+	}
+	if is_doc {
+		if fname == "" {
+			fmt.Println("No file to document")
+			os.Exit(1)
+		}
+		unit, err := load_unit(fname)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for _, f := range unit.Funcs() {
+			fmt.Printf("%s:%d\n%s\n", fname, f.Line, f)
+		}
+		return
+	}
+	if is_compile {
+		if fname == "" {
+			fmt.Println("No file to compile")
+			os.Exit(1)
+		}
+		if err := compile_file(fname); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
-		in.Nothing("Nothing")
-		in.Run(fmt.Sprintf("_node_%d", bytecode.NodeN-1))
+		return
+	}
+	if fname != "" {
+		unit, err := load_unit(fname)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		run_unit(unit, fname)
 		return
 	}
 	// REPL START
@@ -314,7 +486,7 @@ func main() {
 	defer rl.Close()
 	in := inter.NewInterpreter(`!print "[Minimum v"+(!system "version")+" on "+(!system "os")+"]"`, ".") //Interpreter{}
 	in.Nothing("Nothing")
-	in.Run(fmt.Sprintf("_node_%d", bytecode.NodeN-1))
+	in.Run(in.Entry)
 	for {
 		in.GCE()
 		source, err_ := rl.Readline()
@@ -351,8 +523,11 @@ func main() {
 			quote_c += strings.Count(sourcelet, "\"")
 		}
 		rl.SetPrompt("?>>")
-		in.Compile(source, ".")
-		last_node := fmt.Sprintf("_node_%d", bytecode.NodeN-1)
+		last_node, cerr := in.Compile(source, ".")
+		if cerr != nil {
+			fmt.Print(cerr.(bytecode.SyntaxErrors).Report("<stdin>"))
+			continue
+		}
 		if len(in.Code[last_node]) == 0 {
 			continue
 		}
@@ -0,0 +1,406 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PACKAGES START
+
+// `minimum pkg install` vendors the dependencies listed in the minimum.json
+// manifest of the current directory into min_modules/<name>, where import
+// finds them, and records what it installed in minimum.lock. A dependency
+// is a local directory or a git repository, either of them possibly holding
+// a manifest of its own whose dependencies are installed next to it.
+// `minimum pkg update` does the same but checks the git packages out at their
+// ref again instead of the commit locked, and `minimum pkg verify` checks
+// min_modules against the lockfile.
+
+const (
+	manifestFile = "minimum.json"
+	lockFile     = "minimum.lock"
+	modulesDir   = "min_modules"
+	stagePrefix  = ".min_modules-" // the directory an install is written to first
+)
+
+// manifest is the minimum.json file of a project or a package.
+type manifest struct {
+	Name         string                `json:"name,omitempty"`
+	Dependencies map[string]dependency `json:"dependencies"`
+}
+
+// dependency is where a package comes from: Path, a directory relative to
+// the manifest, or Git, a repository checked out at Ref (its default branch
+// when empty).
+type dependency struct {
+	Path string `json:"path,omitempty"`
+	Git  string `json:"git,omitempty"`
+	Ref  string `json:"ref,omitempty"`
+}
+
+// locked is a package as installed: its dependency, the commit of a git one
+// and the hash of the files written to min_modules.
+type locked struct {
+	dependency
+	Commit string `json:"commit,omitempty"`
+	Hash   string `json:"hash"`
+}
+
+type lockfile struct {
+	Packages map[string]locked `json:"packages"`
+}
+
+// run_pkg runs the pkg subcommand given by args in the project directory dir.
+func run_pkg(dir string, args []string) error {
+	command := "install"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch command {
+	case "install", "update":
+		return pkg_install(dir, command == "update")
+	case "verify":
+		return pkg_verify(dir)
+	}
+	return fmt.Errorf("unknown pkg command %q, expected install, update or verify", command)
+}
+
+func read_json(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// pkg_install installs the dependencies of the manifest of dir and those of
+// its packages, checking a git package out at the commit the lockfile holds
+// while its source and ref stay the same unless update is set, and rewrites
+// the lockfile. The packages are written to a directory next to min_modules
+// which replaces it once all of them are installed, so a failed install
+// leaves min_modules and the lockfile as they were; a package that is no
+// longer needed is thereby removed.
+func pkg_install(dir string, update bool) error {
+	m := manifest{}
+	if err := read_json(filepath.Join(dir, manifestFile), &m); err != nil {
+		return err
+	}
+	old := lockfile{}
+	if err := read_json(filepath.Join(dir, lockFile), &old); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	tmp, err := os.MkdirTemp("", "minimum-pkg-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	stage, err := os.MkdirTemp(dir, stagePrefix)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stage)
+	if err := os.Chmod(stage, 0755); err != nil {
+		return err
+	}
+
+	type pending struct {
+		name string
+		dep  dependency
+		base string // directory of the manifest listing it, "" for a remote repository
+	}
+	queue := []pending{}
+	add := func(deps map[string]dependency, base string) {
+		names := make([]string, 0, len(deps))
+		for name := range deps {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			queue = append(queue, pending{name, deps[name], base})
+		}
+	}
+	add(m.Dependencies, dir)
+	lock := lockfile{Packages: make(map[string]locked)}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if err := check_dependency(p.name, p.dep); err != nil {
+			return err
+		}
+		if source := p.dep.Path + p.dep.Git; p.base == "" && !filepath.IsAbs(source) && !remote(source) {
+			return fmt.Errorf("package %s: %s is relative to a remote git repository", p.name, source)
+		}
+		if installed, ok := lock.Packages[p.name]; ok {
+			if installed.dependency != p.dep {
+				return fmt.Errorf("package %s is required from both %s and %s", p.name, describe_dependency(installed.dependency), describe_dependency(p.dep))
+			}
+			continue
+		}
+		prev, was_locked := old.Packages[p.name]
+		// home is where the paths its own manifest lists are relative to: the
+		// package directory or the repository it was cloned from, not the clone
+		src, home, commit := "", "", ""
+		if p.dep.Path != "" {
+			src = join_base(p.base, p.dep.Path)
+			home = src
+		} else {
+			ref := p.dep.Ref
+			if was_locked && prev.dependency == p.dep && prev.Commit != "" && !update {
+				ref = prev.Commit
+			}
+			repo := join_base(p.base, p.dep.Git)
+			if !remote(repo) {
+				home = repo
+			}
+			src = filepath.Join(tmp, p.name)
+			if commit, err = git_checkout(repo, ref, src); err != nil {
+				return fmt.Errorf("package %s: %v", p.name, err)
+			}
+		}
+		dst := filepath.Join(stage, p.name)
+		if err := copy_tree(src, dst); err != nil {
+			return fmt.Errorf("package %s: %v", p.name, err)
+		}
+		hash, err := hash_tree(dst)
+		if err != nil {
+			return err
+		}
+		if was_locked && prev.dependency == p.dep && commit != "" && commit == prev.Commit && hash != prev.Hash {
+			return fmt.Errorf("package %s: commit %s does not match the hash of %s", p.name, commit, lockFile)
+		}
+		lock.Packages[p.name] = locked{dependency: p.dep, Commit: commit, Hash: hash}
+		fmt.Printf("%s %s %s\n", p.name, describe_dependency(p.dep), short_hash(hash))
+		sub := manifest{}
+		if err := read_json(filepath.Join(src, manifestFile), &sub); err == nil {
+			add(sub.Dependencies, home)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("package %s: %v", p.name, err)
+		}
+	}
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	staged_lock := stage + ".lock"
+	defer os.Remove(staged_lock)
+	if err := os.WriteFile(staged_lock, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	if err := replace_modules(dir, stage); err != nil {
+		return err
+	}
+	return os.Rename(staged_lock, filepath.Join(dir, lockFile))
+}
+
+// replace_modules makes the packages installed in stage the min_modules of
+// dir, keeping the files of the old one that are not packages.
+func replace_modules(dir, stage string) error {
+	modules := filepath.Join(dir, modulesDir)
+	old := stage + ".old"
+	if err := os.Rename(modules, old); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	defer os.RemoveAll(old)
+	if err := os.Rename(stage, modules); err != nil {
+		os.Rename(old, modules)
+		return err
+	}
+	entries, err := os.ReadDir(old)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			if err := os.Rename(filepath.Join(old, entry.Name()), filepath.Join(modules, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pkg_verify reports the packages of the lockfile of dir whose files in
+// min_modules are missing or differ from the ones installed.
+func pkg_verify(dir string) error {
+	lock := lockfile{}
+	if err := read_json(filepath.Join(dir, lockFile), &lock); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(lock.Packages))
+	for name := range lock.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	failed := 0
+	for _, name := range names {
+		hash, err := hash_tree(filepath.Join(dir, modulesDir, name))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			fmt.Printf("%s: missing\n", name)
+			failed++
+		case err != nil:
+			return err
+		case hash != lock.Packages[name].Hash:
+			fmt.Printf("%s: modified, %s instead of %s\n", name, short_hash(hash), short_hash(lock.Packages[name].Hash))
+			failed++
+		default:
+			fmt.Printf("%s: ok\n", name)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d packages do not match %s", failed, len(names), lockFile)
+	}
+	return nil
+}
+
+func check_dependency(name string, dep dependency) error {
+	switch {
+	case name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`):
+		return fmt.Errorf("invalid package name %q", name)
+	case (dep.Path == "") == (dep.Git == ""):
+		return fmt.Errorf("package %s needs either a \"path\" or a \"git\" source", name)
+	case dep.Path != "" && dep.Ref != "":
+		return fmt.Errorf("package %s: \"ref\" only applies to git sources", name)
+	case strings.HasPrefix(dep.Git, "-") || strings.HasPrefix(dep.Ref, "-"):
+		// git would take them for options
+		return fmt.Errorf("package %s: a git source or ref cannot start with \"-\"", name)
+	}
+	return nil
+}
+
+func describe_dependency(dep dependency) string {
+	switch {
+	case dep.Path != "":
+		return "path " + dep.Path
+	case dep.Ref != "":
+		return "git " + dep.Git + "@" + dep.Ref
+	}
+	return "git " + dep.Git
+}
+
+// join_base resolves the path or git location source written in the manifest
+// of the directory base. URLs are kept as they are.
+func join_base(base, source string) string {
+	if filepath.IsAbs(source) || remote(source) {
+		return source
+	}
+	return filepath.Join(base, source)
+}
+
+// remote tells whether the git location source is a URL rather than a path.
+func remote(source string) bool {
+	return strings.Contains(source, "://") || strings.HasPrefix(source, "git@")
+}
+
+// git_checkout clones the repository repo into dst at ref and returns the
+// commit checked out.
+func git_checkout(repo, ref, dst string) (string, error) {
+	git := func(args ...string) (string, error) {
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("git %s: %v\n%s", args[0], err, bytes.TrimSpace(out))
+		}
+		return string(bytes.TrimSpace(out)), nil
+	}
+	if _, err := git("clone", "--quiet", "--", repo, dst); err != nil {
+		return "", err
+	}
+	if ref != "" {
+		if _, err := git("-C", dst, "checkout", "--quiet", ref); err != nil {
+			return "", err
+		}
+	}
+	return git("-C", dst, "rev-parse", "HEAD")
+}
+
+// vendored tells whether the file or directory at rel, relative to the root
+// of a package, is copied into min_modules.
+func vendored(rel string) bool {
+	return rel != ".git" && rel != modulesDir && !strings.HasPrefix(rel, stagePrefix)
+}
+
+// copy_tree copies the regular files and directories under src to dst,
+// keeping their permissions.
+func copy_tree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		if !vendored(rel) {
+			return ternary(d.IsDir(), filepath.SkipDir, nil)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case !info.Mode().IsRegular():
+			return nil
+		}
+		from, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer from.Close()
+		to, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(to, from); err != nil {
+			to.Close()
+			return err
+		}
+		return to.Close()
+	})
+}
+
+// hash_tree hashes the regular files under dir with their paths, the same
+// files giving the same hash wherever they are.
+func hash_tree(dir string) (string, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		fmt.Fprintf(h, "%x  %s\n", sha256.Sum256(data), filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
+
+func short_hash(hash string) string {
+	return hash[:min(len(hash), len("sha256:")+12)]
+}
+
+// PACKAGES END
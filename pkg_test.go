@@ -0,0 +1,237 @@
+//go:build !js
+// +build !js
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// write_files writes the files, by path relative to dir, creating their
+// directories.
+func write_files(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// git_commit writes the files into the repository dir, creating it if
+// needed, and commits them.
+func git_commit(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		git("init", "--quiet")
+	}
+	write_files(t, dir, files)
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "commit")
+}
+
+func read_file(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func read_lock(t *testing.T, dir string) lockfile {
+	t.Helper()
+	lock := lockfile{}
+	if err := read_json(filepath.Join(dir, lockFile), &lock); err != nil {
+		t.Fatal(err)
+	}
+	return lock
+}
+
+func TestPkgInstall(t *testing.T) {
+	root := t.TempDir()
+	git_commit(t, filepath.Join(root, "lib"), map[string]string{"lib.min": "x = 1\n"})
+	write_files(t, root, map[string]string{
+		"text/text.min": "y = 2\n",
+		"proj/minimum.json": `{"dependencies": {
+			"lib": {"git": "../lib"},
+			"text": {"path": "../text"}
+		}}`,
+	})
+	proj := filepath.Join(root, "proj")
+	if err := pkg_install(proj, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := read_file(t, filepath.Join(proj, modulesDir, "lib", "lib.min")); got != "x = 1\n" {
+		t.Errorf("lib.min holds %q", got)
+	}
+	if got := read_file(t, filepath.Join(proj, modulesDir, "text", "text.min")); got != "y = 2\n" {
+		t.Errorf("text.min holds %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(proj, modulesDir, "lib", ".git")); err == nil {
+		t.Error(".git was vendored")
+	}
+	lock := read_lock(t, proj)
+	if len(lock.Packages) != 2 || lock.Packages["lib"].Commit == "" || lock.Packages["text"].Hash == "" {
+		t.Errorf("unexpected lockfile %+v", lock)
+	}
+
+	// a package no longer required is removed
+	write_files(t, proj, map[string]string{"minimum.json": `{"dependencies": {"text": {"path": "../text"}}}`})
+	if err := pkg_install(proj, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(proj, modulesDir, "lib")); err == nil {
+		t.Error("lib was not removed")
+	}
+	if lock := read_lock(t, proj); len(lock.Packages) != 1 {
+		t.Errorf("unexpected lockfile %+v", lock)
+	}
+}
+
+func TestPkgVerify(t *testing.T) {
+	root := t.TempDir()
+	git_commit(t, filepath.Join(root, "lib"), map[string]string{"lib.min": "x = 1\n"})
+	write_files(t, root, map[string]string{"proj/minimum.json": `{"dependencies": {"lib": {"git": "../lib"}}}`})
+	proj := filepath.Join(root, "proj")
+	if err := pkg_install(proj, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := pkg_verify(proj); err != nil {
+		t.Fatalf("verify after install: %v", err)
+	}
+	write_files(t, proj, map[string]string{"min_modules/lib/lib.min": "x = 2\n"})
+	if err := pkg_verify(proj); err == nil {
+		t.Error("verify accepted a modified package")
+	}
+	if err := os.RemoveAll(filepath.Join(proj, modulesDir, "lib")); err != nil {
+		t.Fatal(err)
+	}
+	if err := pkg_verify(proj); err == nil {
+		t.Error("verify accepted a missing package")
+	}
+}
+
+func TestPkgUpdate(t *testing.T) {
+	root := t.TempDir()
+	lib := filepath.Join(root, "lib")
+	git_commit(t, lib, map[string]string{"lib.min": "x = 1\n"})
+	write_files(t, root, map[string]string{"proj/minimum.json": `{"dependencies": {"lib": {"git": "../lib"}}}`})
+	proj := filepath.Join(root, "proj")
+	if err := pkg_install(proj, false); err != nil {
+		t.Fatal(err)
+	}
+	first := read_lock(t, proj).Packages["lib"].Commit
+	git_commit(t, lib, map[string]string{"lib.min": "x = 2\n"})
+
+	// install keeps the commit locked
+	if err := pkg_install(proj, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := read_lock(t, proj).Packages["lib"].Commit; got != first {
+		t.Errorf("install moved lib from %s to %s", first, got)
+	}
+	if got := read_file(t, filepath.Join(proj, modulesDir, "lib", "lib.min")); got != "x = 1\n" {
+		t.Errorf("after install lib.min holds %q", got)
+	}
+
+	// update checks the ref out again
+	if err := pkg_install(proj, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := read_lock(t, proj).Packages["lib"].Commit; got == first {
+		t.Error("update kept the commit locked")
+	}
+	if got := read_file(t, filepath.Join(proj, modulesDir, "lib", "lib.min")); got != "x = 2\n" {
+		t.Errorf("after update lib.min holds %q", got)
+	}
+}
+
+func TestPkgRelativePathDependency(t *testing.T) {
+	root := t.TempDir()
+	write_files(t, root, map[string]string{
+		"helper/helper.min": "h = 1\n",
+		"proj/minimum.json": `{"dependencies": {"lib": {"git": "../lib"}}}`,
+	})
+	// the path is relative to the repository, not to its clone
+	git_commit(t, filepath.Join(root, "lib"), map[string]string{
+		"lib.min":      "x = 1\n",
+		"minimum.json": `{"dependencies": {"helper": {"path": "../helper"}}}`,
+	})
+	proj := filepath.Join(root, "proj")
+	if err := pkg_install(proj, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := read_file(t, filepath.Join(proj, modulesDir, "helper", "helper.min")); got != "h = 1\n" {
+		t.Errorf("helper.min holds %q", got)
+	}
+}
+
+func TestPkgFailedInstall(t *testing.T) {
+	root := t.TempDir()
+	lib := filepath.Join(root, "lib")
+	git_commit(t, lib, map[string]string{"lib.min": "x = 1\n"})
+	write_files(t, root, map[string]string{"proj/minimum.json": `{"dependencies": {"lib": {"git": "../lib"}}}`})
+	proj := filepath.Join(root, "proj")
+	if err := pkg_install(proj, false); err != nil {
+		t.Fatal(err)
+	}
+	lock := read_file(t, filepath.Join(proj, lockFile))
+	// lib is updated before the missing repository fails
+	git_commit(t, lib, map[string]string{"lib.min": "x = 2\n"})
+	write_files(t, proj, map[string]string{"minimum.json": `{"dependencies": {
+		"lib": {"git": "../lib"},
+		"zz": {"git": "../missing"}
+	}}`})
+	if err := pkg_install(proj, true); err == nil {
+		t.Fatal("installing a missing repository succeeded")
+	}
+	if got := read_file(t, filepath.Join(proj, lockFile)); got != lock {
+		t.Errorf("the failed install rewrote the lockfile:\n%s", got)
+	}
+	if err := pkg_verify(proj); err != nil {
+		t.Errorf("the failed install changed min_modules: %v", err)
+	}
+	entries, err := os.ReadDir(proj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), stagePrefix) {
+			t.Errorf("the failed install left %s behind", entry.Name())
+		}
+	}
+}
+
+func TestPkgOptionSource(t *testing.T) {
+	proj := t.TempDir()
+	marker := filepath.Join(proj, "ran")
+	write_files(t, proj, map[string]string{"minimum.json": `{"dependencies": {"lib": {"git": "--upload-pack=touch ` + filepath.ToSlash(marker) + `"}}}`})
+	if err := pkg_install(proj, false); err == nil || !strings.Contains(err.Error(), `cannot start with "-"`) {
+		t.Errorf("a git source starting with \"-\" gave %v", err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("the git source ran as an option")
+	}
+}